@@ -0,0 +1,322 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"mylang/ast"
+	"mylang/diagnostic"
+	"mylang/object"
+	"mylang/token"
+)
+
+const (
+	UNUSED_VARIABLE       diagnostic.Kind = "unused-variable"
+	UNUSED_PARAMETER      diagnostic.Kind = "unused-parameter"
+	SHADOWED_BUILTIN      diagnostic.Kind = "shadowed-builtin"
+	REDEFINED_VARIABLE    diagnostic.Kind = "redefined-variable"
+	UNREACHABLE_CODE      diagnostic.Kind = "unreachable-code"
+	UNDECLARED_ASSIGNMENT diagnostic.Kind = "undeclared-assignment"
+	DUPLICATE_DEFAULT     diagnostic.Kind = "duplicate-default"
+)
+
+// a name defined by a let statement or a function parameter
+type variable struct {
+	name string
+	token token.Token
+	parameter bool
+	used bool
+}
+
+// the names defined in a function body or block. The global scope has no
+// outer scope and its variables are never reported as unused since later
+// input, like the next line of the repl, can still use them
+type scope struct {
+	outer *scope
+	variables map[string]*variable
+	order []*variable
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, variables: make(map[string]*variable)}
+}
+
+// looks up the name from the innermost scope outwards
+func (s *scope) resolve(name string) (*variable, bool) {
+	for current := s; current != nil; current = current.outer {
+		if v, ok := current.variables[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// semantic analysis pass run over the ast before it is evaluated or
+// compiled. Keeps the global scope between calls to Analyze so that
+// programs given one after another, like the lines of the repl, see
+// the globals defined before them
+type Analyzer struct {
+	globals *scope
+	declared map[string]bool
+	diagnostics []diagnostic.Diagnostic
+}
+
+func New() *Analyzer {
+	return &Analyzer{
+		globals: newScope(nil),
+		declared: make(map[string]bool),
+	}
+}
+
+// analyzes a single program with a fresh analyzer
+func Analyze(program *ast.Program) []diagnostic.Diagnostic {
+	return New().Analyze(program)
+}
+
+// walks the program and returns the diagnostics found, ordered by
+// their position in the input
+func (a *Analyzer) Analyze(program *ast.Program) []diagnostic.Diagnostic {
+	a.diagnostics = []diagnostic.Diagnostic{}
+
+	// globals can be assigned by functions defined before them, so every
+	// top level name counts as declared for the whole program
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			a.declared[let.Name.Value] = true
+		}
+	}
+
+	a.analyzeStatements(program.Statements, a.globals)
+
+	sort.SliceStable(a.diagnostics, func(i, j int) bool {
+		if a.diagnostics[i].Line != a.diagnostics[j].Line {
+			return a.diagnostics[i].Line < a.diagnostics[j].Line
+		}
+		return a.diagnostics[i].Column < a.diagnostics[j].Column
+	})
+
+	return a.diagnostics
+}
+
+func (a *Analyzer) warn(kind diagnostic.Kind, tok token.Token, format string,
+	args ...interface{}) {
+	a.diagnostics = append(a.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.WARNING,
+		Kind: kind,
+		Message: fmt.Sprintf(format, args...),
+		Line: tok.Line,
+		Column: tok.Column,
+		Length: len([]rune(tok.Literal)),
+	})
+}
+
+// analyzes a list of statements, reporting the first statement that
+// follows a return since it can never run
+func (a *Analyzer) analyzeStatements(statements []ast.Statement, s *scope) {
+	returned := false
+
+	for _, statement := range statements {
+		if returned {
+			a.warn(UNREACHABLE_CODE, statementToken(statement),
+				"unreachable code after return")
+			returned = false
+		}
+
+		a.analyzeStatement(statement, s)
+
+		if _, ok := statement.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (a *Analyzer) analyzeStatement(statement ast.Statement, s *scope) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		a.analyzeExpression(statement.Value, s)
+		a.define(statement.Name, s, false)
+
+	case *ast.AssignmentStatement:
+		a.analyzeExpression(statement.Value, s)
+
+		_, ok := s.resolve(statement.Name.Value)
+		if !ok && !a.declared[statement.Name.Value] {
+			a.warn(UNDECLARED_ASSIGNMENT, statement.Name.Token,
+				"assignment to undeclared variable %s", statement.Name.Value)
+		}
+
+	case *ast.ReturnStatement:
+		a.analyzeExpression(statement.ReturnValue, s)
+
+	case *ast.ExpressionStatement:
+		a.analyzeExpression(statement.Expression, s)
+
+	case *ast.BlockStatement:
+		a.analyzeBlock(statement, s)
+	}
+}
+
+// analyzes a block in its own scope, the same way both engines run the
+// bodies of ifs, whiles, and switches
+func (a *Analyzer) analyzeBlock(block *ast.BlockStatement, outer *scope) {
+	if block == nil {
+		return
+	}
+
+	s := newScope(outer)
+	a.analyzeStatements(block.Statements, s)
+	a.reportUnused(s)
+}
+
+func (a *Analyzer) analyzeExpression(expression ast.Expression, s *scope) {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		if v, ok := s.resolve(expression.Value); ok {
+			v.used = true
+		}
+
+	case *ast.PrefixExpression:
+		a.analyzeExpression(expression.Right, s)
+
+	case *ast.InfixExpression:
+		a.analyzeExpression(expression.Left, s)
+		a.analyzeExpression(expression.Right, s)
+
+	case *ast.IndexExpression:
+		a.analyzeExpression(expression.Left, s)
+		a.analyzeExpression(expression.Index, s)
+
+	case *ast.SliceExpression:
+		a.analyzeExpression(expression.Left, s)
+		for _, bound := range []ast.Expression{expression.Start, expression.End, expression.Step} {
+			if bound != nil {
+				a.analyzeExpression(bound, s)
+			}
+		}
+
+	case *ast.CallExpression:
+		a.analyzeExpression(expression.Function, s)
+		for _, argument := range expression.Arguments {
+			a.analyzeExpression(argument, s)
+		}
+
+	case *ast.ArrayLiteral:
+		for _, element := range expression.Elements {
+			a.analyzeExpression(element, s)
+		}
+
+	case *ast.InterpolatedString:
+		for _, part := range expression.Parts {
+			a.analyzeExpression(part, s)
+		}
+
+	case *ast.HashLiteral:
+		for _, key := range expression.Keys {
+			a.analyzeExpression(key, s)
+			a.analyzeExpression(expression.Pairs[key], s)
+		}
+
+	case *ast.IfExpression:
+		a.analyzeExpression(expression.Condition, s)
+		a.analyzeBlock(expression.Consequence, s)
+		a.analyzeBlock(expression.Alternative, s)
+
+	case *ast.WhileExpression:
+		a.analyzeExpression(expression.Condition, s)
+		a.analyzeBlock(expression.Body, s)
+
+	case *ast.SwitchExpression:
+		a.analyzeExpression(expression.Value, s)
+
+		defaults := 0
+		for _, choice := range expression.Cases {
+			if choice.Default {
+				defaults++
+				if defaults == 2 {
+					a.warn(DUPLICATE_DEFAULT, choice.Token,
+						"switch has more than one default case")
+				}
+			} else {
+				a.analyzeExpression(choice.Value, s)
+			}
+			a.analyzeBlock(choice.Body, s)
+		}
+
+	// the parameters and the body share the function's scope. The name
+	// of the function is visible inside it but is not a variable that
+	// needs to be used
+	case *ast.FunctionLiteral:
+		fs := newScope(s)
+		if expression.Name != "" {
+			fs.variables[expression.Name] = &variable{
+				name: expression.Name,
+				used: true,
+			}
+		}
+
+		for _, parameter := range expression.Parameters {
+			a.define(parameter, fs, true)
+		}
+
+		a.analyzeStatements(expression.Body.Statements, fs)
+		a.reportUnused(fs)
+	}
+}
+
+// adds the name to the scope, warning if it replaces a builtin or a
+// variable already defined in the same scope
+func (a *Analyzer) define(name *ast.Identifier, s *scope, parameter bool) {
+	if object.GetBuiltinByName(name.Value) != nil {
+		a.warn(SHADOWED_BUILTIN, name.Token,
+			"%s shadows the builtin function of the same name", name.Value)
+	}
+
+	if previous, ok := s.variables[name.Value]; ok && previous.token.Type != "" {
+		a.warn(REDEFINED_VARIABLE, name.Token,
+			"%s is already defined in this scope", name.Value)
+	}
+
+	v := &variable{name: name.Value, token: name.Token, parameter: parameter}
+	s.variables[name.Value] = v
+	s.order = append(s.order, v)
+}
+
+// warns about the variables of a finished scope that were never read.
+// Names starting with an underscore are left alone so they can mark
+// values that are unused on purpose
+func (a *Analyzer) reportUnused(s *scope) {
+	if s.outer == nil {
+		return
+	}
+
+	for _, v := range s.order {
+		if v.used || strings.HasPrefix(v.name, "_") {
+			continue
+		}
+
+		if v.parameter {
+			a.warn(UNUSED_PARAMETER, v.token, "parameter %s is never used", v.name)
+		} else {
+			a.warn(UNUSED_VARIABLE, v.token, "%s is defined but never used", v.name)
+		}
+	}
+}
+
+// returns the token a statement starts at
+func statementToken(statement ast.Statement) token.Token {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token
+	case *ast.AssignmentStatement:
+		return statement.Name.Token
+	case *ast.ReturnStatement:
+		return statement.Token
+	case *ast.ExpressionStatement:
+		return statement.Token
+	case *ast.BlockStatement:
+		return statement.Token
+	case *ast.BadStatement:
+		return statement.Token
+	}
+	return token.Token{}
+}
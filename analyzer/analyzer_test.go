@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"mylang/ast"
+	"mylang/diagnostic"
+	"mylang/lexer"
+	"mylang/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let f = func(a, b) { let c = 1; a };",
+			[]string{
+				"1:17: warning: parameter b is never used",
+				"1:26: warning: c is defined but never used",
+			},
+		},
+		{
+			"let f = func(_a) { let _b = 1; 2 };",
+			[]string{},
+		},
+		{
+			"let unused = 1; let len = func(x) { x }; let f = func(puts) { puts };",
+			[]string{
+				"1:21: warning: len shadows the builtin function of the same name",
+				"1:55: warning: puts shadows the builtin function of the same name",
+			},
+		},
+		{
+			"let f = func() {\n\treturn 1;\n\tputs(2);\n\tputs(3);\n};",
+			[]string{"3:2: warning: unreachable code after return"},
+		},
+		{
+			"let f = func() { x = 1; y = 2; }; let x = 0;",
+			[]string{"1:25: warning: assignment to undeclared variable y"},
+		},
+		{
+			"switch (1) { case 1 { 1 } default { 2 } default { 3 } }",
+			[]string{"1:41: warning: switch has more than one default case"},
+		},
+		{
+			"let a = 1; let a = 2; if (true) { let a = 3; a };",
+			[]string{"1:16: warning: a is already defined in this scope"},
+		},
+		{
+			`
+			let counter = func() {
+				let count = 0;
+				func() { count = count + 1; count };
+			};
+			let fib = func(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+			`,
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Analyze(parse(t, tt.input))
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("wrong number of diagnostics for %q. want=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(diagnostics), diagnostics)
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			if diagnostics[i].String() != expected {
+				t.Errorf("wrong diagnostic. want=%q, got=%q",
+					expected, diagnostics[i].String())
+			}
+		}
+	}
+}
+
+func TestDiagnosticKinds(t *testing.T) {
+	diagnostics := Analyze(parse(t, `
+	let f = func(a) { return 1; 2 };
+	let len = 1;
+	`))
+
+	expected := []diagnostic.Kind{UNUSED_PARAMETER, UNREACHABLE_CODE, SHADOWED_BUILTIN}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. want=%d, got=%d (%v)",
+			len(expected), len(diagnostics), diagnostics)
+	}
+
+	for i, kind := range expected {
+		if diagnostics[i].Kind != kind {
+			t.Errorf("wrong kind. want=%s, got=%s", kind, diagnostics[i].Kind)
+		}
+		if diagnostics[i].Severity != diagnostic.WARNING {
+			t.Errorf("wrong severity. want=%s, got=%s", diagnostic.WARNING, diagnostics[i].Severity)
+		}
+	}
+}
+
+func TestAnalyzerKeepsGlobals(t *testing.T) {
+	a := New()
+
+	diagnostics := a.Analyze(parse(t, "let x = 1;"))
+	if len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+
+	diagnostics = a.Analyze(parse(t, "x = 2;"))
+	if len(diagnostics) != 0 {
+		t.Errorf("x should still be declared. got=%v", diagnostics)
+	}
+
+	diagnostics = a.Analyze(parse(t, "let x = 3;"))
+	if len(diagnostics) != 1 || diagnostics[0].Kind != REDEFINED_VARIABLE {
+		t.Errorf("expected redefinition warning. got=%v", diagnostics)
+	}
+}
@@ -28,6 +28,15 @@ type Expression interface {
 // statment, or a let statment.
 type Program struct {
 	Statements []Statement
+	// only filled in when the lexer keeps comments, see lexer.NewWithComments
+	Comments []*Comment
+}
+
+// a comment in the source. Trailing comments follow code on the same
+// line, the others sit on lines of their own
+type Comment struct {
+	Token token.Token
+	Trailing bool
 }
 // implements the Node interface
 func (p *Program) TokenLiteral() string {
@@ -145,6 +154,8 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token token.Token
 	Statements []Statement
+	// the closing brace, where the block ends
+	Close token.Token
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -200,6 +211,30 @@ func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string { return sl.Token.Literal }
 
 
+// a string with ${} in it. Parts holds the pieces in order, StringLiterals
+// for the text and any expression for what is interpolated
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if literal, ok := part.(*StringLiteral); ok {
+			out.WriteString(literal.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
+
 type ArrayLiteral struct {
 	Token token.Token
 	Elements []Expression
@@ -223,9 +258,12 @@ func (al *ArrayLiteral) String() string {
 }
 
 
+// Keys holds the keys of Pairs in the order they appear in the source,
+// which is the order the pairs are put in the hash
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys []Expression
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -234,10 +272,10 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String() + ":" + value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String() + ":" + hl.Pairs[key].String())
 	}
-	
+
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString("}")
@@ -318,10 +356,13 @@ func (ie *InfixExpression) String() string {
 }
 
 
+// Tail is set by the parser when the call is the last thing the enclosing
+// function does, so the engines can reuse the caller's frame for it
 type CallExpression struct {
 	Token token.Token
 	Function Expression
 	Arguments []Expression
+	Tail bool
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -442,6 +483,40 @@ func (se *SwitchExpression) String() string {
 }
 
 
+// a[start:end] or a[start:end:step]. Bounds that are left out are nil
+type SliceExpression struct {
+	Token token.Token
+	Left Expression
+	Start Expression
+	End Expression
+	Step Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+
 type IndexExpression struct {
 	Token token.Token
 	Left Expression
@@ -461,3 +536,24 @@ func (ie *IndexExpression) String() string {
 
 	return out.String()
 }
+
+
+// placeholders left by the parser where it could not parse a statement or
+// expression, so the rest of a broken program still forms a tree. Token
+// is where the broken code starts
+type BadStatement struct {
+	Token token.Token
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) String() string { return "<bad statement>" }
+
+
+type BadExpression struct {
+	Token token.Token
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) String() string { return "<bad expression>" }
@@ -35,6 +35,7 @@ const (
 	OpHash
 	OpIndex
 	OpCall
+	OpTailCall
 	OpReturn
 	OpReturnValue
 	OpJump
@@ -45,11 +46,16 @@ const (
 	OpGetLocal
 	OpSetFree
 	OpGetFree
+	OpCaptureLocal
+	OpCaptureFree
+	OpClose
 	OpClosure
 	OpCurrentClosure
 	OpGetBuiltin
 	OpPop
 	OpNull
+	OpInterpolate
+	OpSlice
 )
 
 var definitions = map[Opcode]*Definition{
@@ -72,6 +78,7 @@ var definitions = map[Opcode]*Definition{
 	OpHash:           {"OpHash",           []int{2}},
 	OpIndex:          {"OpIndex",          []int{}},
 	OpCall:           {"OpCall",           []int{1}},
+	OpTailCall:       {"OpTailCall",       []int{1}},
 	OpReturn:         {"OpReturn",         []int{}},
 	OpReturnValue:    {"OpReturnValue",    []int{}},
 	OpJump:           {"OpJump",           []int{2}},
@@ -82,11 +89,16 @@ var definitions = map[Opcode]*Definition{
 	OpGetLocal:       {"OpGetLocal",       []int{1}},
 	OpSetFree:        {"OpSetFree",        []int{1}},
 	OpGetFree:        {"OpGetFree",        []int{1}},
+	OpCaptureLocal:   {"OpCaptureLocal",   []int{1}},
+	OpCaptureFree:    {"OpCaptureFree",    []int{1}},
+	OpClose:          {"OpClose",          []int{1}},
 	OpGetBuiltin:     {"OpGetBuiltin",     []int{1}},
 	OpClosure:        {"OpClosure",        []int{2, 1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
 	OpPop:            {"OpPop",            []int{}},
 	OpNull:           {"OpNull",           []int{}},
+	OpInterpolate:    {"OpInterpolate",    []int{2}},
+	OpSlice:          {"OpSlice",          []int{}},
 }
 
 // returns a string representation of the list of instructions
@@ -89,3 +89,36 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestPositionAt(t *testing.T) {
+	positions := []Position{
+		{Offset: 0, Line: 1, Column: 1, Length: 3},
+		{Offset: 4, Line: 1, Column: 7, Length: 1},
+		{Offset: 9, Line: 2, Column: 3, Length: 5},
+	}
+
+	tests := []struct {
+		offset int
+		line int
+		column int
+	}{
+		{0, 1, 1},
+		{3, 1, 1},
+		{4, 1, 7},
+		{8, 1, 7},
+		{9, 2, 3},
+		{20, 2, 3},
+	}
+
+	for _, tt := range tests {
+		position := PositionAt(positions, tt.offset)
+		if position.Line != tt.line || position.Column != tt.column {
+			t.Errorf("wrong position at %d. want=%d:%d, got=%d:%d",
+				tt.offset, tt.line, tt.column, position.Line, position.Column)
+		}
+	}
+
+	if position := PositionAt(nil, 5); position.Line != 0 {
+		t.Errorf("expected no position, got=%+v", position)
+	}
+}
@@ -0,0 +1,26 @@
+package code
+
+import "sort"
+
+// where in the source the instructions from Offset on were compiled
+// from, up to the offset of the next position. Line and Column start at
+// 1, Length is the number of characters of the token there
+type Position struct {
+	Offset int
+	Line int
+	Column int
+	Length int
+}
+
+// finds the position of the instruction at the offset in a list of
+// positions sorted by offset. Returns the zero Position, with a Line of
+// 0, if the instruction has no position
+func PositionAt(positions []Position, offset int) Position {
+	i := sort.Search(len(positions), func(i int) bool {
+		return positions[i].Offset > offset
+	})
+	if i == 0 {
+		return Position{}
+	}
+	return positions[i - 1]
+}
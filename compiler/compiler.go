@@ -2,7 +2,8 @@ package compiler
 
 import (
 	"fmt"
-	"sort"
+	"strings"
+	"unicode/utf8"
 	"mylang/ast"
 	"mylang/code"
 	"mylang/object"
@@ -16,17 +17,47 @@ type Compiler struct {
 
 	scopes []CompilationScope
 	scopeIndex int
+
+	// the token of the node being compiled, the instructions emitted are
+	// marked with its position so the vm can show where an error is
+	token token.Token
 }
 
 type CompilationScope struct {
 	instructions code.Instructions
+	positions []code.Position
 	lastInstruction EmittedInstruction
 	beforeLastInstruction EmittedInstruction
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
+	Positions []code.Position
 	Constants []object.Object
+	NumLocals int
+}
+
+// the instructions of the bytecode followed by those of every compiled
+// function among the constants, starting at the constant index from
+func (b *Bytecode) Disassemble(from int) string {
+	var out strings.Builder
+	out.WriteString(b.Instructions.String())
+
+	for i := from; i < len(b.Constants); i++ {
+		fn, ok := b.Constants[i].(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		fmt.Fprintf(&out, "\nconstant %d, function %s:\n", i, name)
+		out.WriteString(fn.Instructions.String())
+	}
+
+	return out.String()
 }
 
 type EmittedInstruction struct {
@@ -55,7 +86,23 @@ func New() *Compiler {
 	}
 }
 
+// creates a compiler that continues from the symbol table and constants
+// of an earlier compilation, so that the repl can compile each input
+// with the globals defined by the ones before it
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
+	if tok, ok := sourceToken(node); ok {
+		outer := c.token
+		c.token = tok
+		defer func() { c.token = outer }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, statement := range node.Statements {
@@ -69,13 +116,25 @@ func (c *Compiler) Compile(node ast.Node) error {
 	// to refer to that name in the operand of the set operation.
 	// the set operation tells the vm that the value on top of the
 	// stack is to be associated with the given symbol index
+	// globals are defined before the value is compiled so they can refer
+	// to themselves, locals after so that the value can use the variable
+	// the new one shadows ie "let x = x + 1" in a block
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		var symbol Symbol
+		global := c.symbolTable.atGlobalLevel()
+		if global {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
 
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
+
+		if !global {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
 		if symbol.Scope == GlobalScope {
 			c.emit(code.OpSetGlobal, symbol.Index)
 		} else {
@@ -85,7 +144,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.AssignmentStatement:
 		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Name.Value)
+			return newCompileError(node.Name.Token, "undefined variable %s",
+				node.Name.Value)
 		}
 
 		err := c.Compile(node.Value)
@@ -100,7 +160,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case FreeScope:
 			c.emit(code.OpSetFree, symbol.Index)
 		default:
-			return fmt.Errorf("cannot redefine functions")
+			return newCompileError(node.Name.Token, "cannot redefine functions")
 		}
 	
 	// the return value operation tells the vm that the return value
@@ -149,17 +209,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		jumpFalsePos := c.emit(code.OpJumpFalse, 9999)
 
-		err = c.Compile(node.Consequence)
-
+		err = c.compileBlock(node.Consequence, true)
 		if err != nil {
 			return err
 		}
-
-		if !c.lastInstructionIs(code.OpPop) {
-			c.emit(code.OpNull)
-		} else {
-			c.removePop()
-		}
 		
 		jumpPos := c.emit(code.OpJump, 9999)
 		afterConsequencePos := len(c.currentInstructions())
@@ -168,15 +221,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
 		} else {
-			err := c.Compile(node.Alternative)
+			err := c.compileBlock(node.Alternative, true)
 			if err != nil {
 				return err
 			}
-			if !c.lastInstructionIs(code.OpPop) {
-				c.emit(code.OpNull)
-			} else {
-				c.removePop()
-			}
 		}
 
 
@@ -192,7 +240,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		
 		jumpFalsePos := c.emit(code.OpJumpFalse, 9999)
 
-		err = c.Compile(node.Body)
+		err = c.compileBlock(node.Body, false)
 		if err != nil {
 			return err
 		}
@@ -223,33 +271,21 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 			jumpFalsePos := c.emit(code.OpJumpFalse, 9999)
 
-			err = c.Compile(choice.Body)
+			err = c.compileBlock(choice.Body, true)
 			if err != nil {
 				return err
 			}
 
-			if !c.lastInstructionIs(code.OpPop) {
-				c.emit(code.OpNull)
-			} else {
-				c.removePop()
-			}
-
 			jumpPositions = append(jumpPositions, c.emit(code.OpJump, 9999))
 			c.changeOperand(jumpFalsePos, len(c.currentInstructions()))
 		}
 
 		for _, choice := range node.Cases {
 			if choice.Default {
-				err := c.Compile(choice.Body)
+				err := c.compileBlock(choice.Body, true)
 				if err != nil {
 					return err
 				}
-				
-				if !c.lastInstructionIs(code.OpPop) {
-					c.emit(code.OpNull)
-				} else {
-					c.removePop()
-				}
 			}
 		}
 
@@ -308,7 +344,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case token.OR:
 			c.emit(code.OpOr)
 		default:
-			return fmt.Errorf("unkown operator %s", node.Operator)
+			return newCompileError(node.Token, "unkown operator %s", node.Operator)
 		}
 	
 	// compiles the expression to the right of the operator.
@@ -325,9 +361,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case token.BANG:
 			c.emit(code.OpNot)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return newCompileError(node.Token, "unknown operator %s", node.Operator)
 		}
 	
+	// left by the parser in place of code it could not parse
+	case *ast.BadStatement:
+		return newCompileError(node.Token, "invalid syntax")
+	case *ast.BadExpression:
+		return newCompileError(node.Token, "invalid syntax")
+
 	case *ast.IndexExpression:
 		err := c.Compile(node.Left)  // left object goes on the stack
 		if err != nil {
@@ -340,11 +382,33 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		c.emit(code.OpIndex)
+
+	// puts the value and the three bounds on the stack, with null for
+	// the bounds that are left out
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		for _, bound := range []ast.Expression{node.Start, node.End, node.Step} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+			err = c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
 	
 	// compiles the function literal and the arguments. The opcall
 	// operation tells the vm that the function literal and the arguments
 	// are on the stack. The operand for the call operation has 
-	// the number of arguments on the stack
+	// the number of arguments on the stack. Calls in tail position of a
+	// function use OpTailCall so the vm can reuse the current frame
 	case *ast.CallExpression:
 		err := c.Compile(node.Function)  // function object goes on the stack
 		if err != nil {
@@ -358,7 +422,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
-		c.emit(code.OpCall, len(node.Arguments))
+		if node.Tail && c.scopeIndex > 0 {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
 	
 	// puts the integer literal on the stack
 	case *ast.IntegerLiteral:
@@ -383,6 +451,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
 	
+	// puts the parts on the stack, the operand is how many the vm joins
+	// into the string
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpInterpolate, len(node.Parts))
+
 	// compiles the elements of the literal. The array operation has
 	// the number of elements that the vm needs to take of the stack
 	// to build the array
@@ -399,16 +479,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 	// just like OpArray, OpHash has the number values the vm has to
 	// take off the stack to construct the hash
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-
-		for _, k := range keys {  // pairs go on the stack
+		for _, k := range node.Keys {  // pairs go on the stack, in source order
 			err := c.Compile(k)
 			if err != nil {
 				return err
@@ -460,15 +531,17 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		freeSymbols := c.symbolTable.FreeSymbols
-		numLocals := c.symbolTable.definitions
+		numLocals := c.symbolTable.NumLocals()
+		positions := c.scopes[c.scopeIndex].positions
 		instructions := c.leaveScope()
 
 		for _, symbol := range freeSymbols {
-			c.loadSymbol(symbol)
+			c.captureSymbol(symbol)
 		}
 
 		compiledFn := &object.CompiledFunction{
 			Instructions: instructions,
+			Positions: positions,
 			NumLocals: numLocals,
 			NumParameters: len(node.Parameters),
 			Name: node.Name,
@@ -485,7 +558,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return newCompileError(node.Token, "undefined variable %s", node.Value)
 		}
 
 		c.loadSymbol(symbol)
@@ -499,10 +572,44 @@ func (c *Compiler) MakeBytecode() *Bytecode {
 	//fmt.Print(c.currentInstructions().String())
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
+		Positions: c.scopes[c.scopeIndex].positions,
 		Constants: c.constants,
+		NumLocals: c.symbolTable.NumLocals(),
 	}
 }
 
+// compiles the body of an if, while, or switch in its own block scope.
+// The block scope is left even when compiling fails, so the symbol table
+// can still be used afterwards, as the repl does.
+// When keepValue is set the value of the last expression is left on the
+// stack as the value of the block, or null if there is none. If the block
+// defined locals, OpClose closes any upvalues pointing at them so that
+// closures made in each pass of a loop keep their own variables
+func (c *Compiler) compileBlock(block *ast.BlockStatement, keepValue bool) error {
+	c.symbolTable.EnterBlock()
+
+	err := c.Compile(block)
+	if err != nil {
+		c.symbolTable.LeaveBlock()
+		return err
+	}
+
+	if keepValue {
+		if !c.lastInstructionIs(code.OpPop) {
+			c.emit(code.OpNull)
+		} else {
+			c.removePop()
+		}
+	}
+
+	start, defined := c.symbolTable.LeaveBlock()
+	if defined > 0 {
+		c.emit(code.OpClose, start)
+	}
+
+	return nil
+}
+
 // makes the instruction for the given opcode and operands, and adds it to
 // the current instructions. Then advances the last and before last
 // instructions in the current scope, and returns the position of the
@@ -510,6 +617,7 @@ func (c *Compiler) MakeBytecode() *Bytecode {
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	var ins code.Instructions = code.Make(op, operands...)
 	var pos int = c.addInstruction(ins)
+	c.markPosition(pos)
 
 	next := EmittedInstruction{Opcode: op, Position: pos}
 	last := c.scopes[c.scopeIndex].lastInstruction
@@ -581,6 +689,65 @@ func (c *Compiler) removePop() {
 
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = beforeLast
+
+	positions := c.scopes[c.scopeIndex].positions
+	for len(positions) > 0 && positions[len(positions) - 1].Offset >= last.Position {
+		positions = positions[:len(positions) - 1]
+	}
+	c.scopes[c.scopeIndex].positions = positions
+}
+
+// marks the instruction at the offset with the position of the token
+// being compiled, unless the instructions before it already have it
+func (c *Compiler) markPosition(offset int) {
+	if c.token.Line == 0 {
+		return
+	}
+
+	positions := c.scopes[c.scopeIndex].positions
+	if len(positions) > 0 {
+		last := positions[len(positions) - 1]
+		if last.Line == c.token.Line && last.Column == c.token.Column {
+			return
+		}
+	}
+
+	c.scopes[c.scopeIndex].positions = append(positions, code.Position{
+		Offset: offset,
+		Line: c.token.Line,
+		Column: c.token.Column,
+		Length: utf8.RuneCountInString(c.token.Literal),
+	})
+}
+
+// the token a node's instructions are marked with. Statements are marked
+// with their first token, and the expressions that can fail at runtime
+// with their operator, or for calls the name of the function
+func sourceToken(node ast.Node) (token.Token, bool) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token, true
+	case *ast.AssignmentStatement:
+		return node.Token, true
+	case *ast.ReturnStatement:
+		return node.Token, true
+	case *ast.ExpressionStatement:
+		return node.Token, true
+	case *ast.PrefixExpression:
+		return node.Token, true
+	case *ast.InfixExpression:
+		return node.Token, true
+	case *ast.IndexExpression:
+		return node.Token, true
+	case *ast.SliceExpression:
+		return node.Token, true
+	case *ast.CallExpression:
+		if name, ok := node.Function.(*ast.Identifier); ok {
+			return name.Token, true
+		}
+		return node.Token, true
+	}
+	return token.Token{}, false
 }
 
 func (c *Compiler) currentInstructions() code.Instructions {
@@ -615,6 +782,21 @@ func (c *Compiler) loadSymbol(s Symbol) {
 	}
 }
 
+// emits the instruction that puts a free variable of a new closure on the
+// stack. Locals and free variables of the enclosing function are captured
+// by reference so that every closure sees the same variable, anything
+// else is captured by value
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
 func (c *Compiler) emptyStack() bool {
 	switch c.scopes[c.scopeIndex].lastInstruction.Opcode {
 	case code.OpSetGlobal,
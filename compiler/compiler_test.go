@@ -331,6 +331,20 @@ func TestStringExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             `"a ${1 + 2} b ${"c"}"`,
+			expectedConstants: []interface{}{"a ", 1, 2, " b ", "c"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpInterpolate, 4),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -410,6 +424,35 @@ func TestIndexExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "[1, 2][1:]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2][:1:-1]",
+			expectedConstants: []interface{}{1, 2, 1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpMinus),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -611,6 +654,117 @@ func TestWhileExpression(t *testing.T) {
 
 }
 
+func TestBlockScopes(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			let x = 1;
+			if (true) { let x = x + 1; x };
+			x;
+			`,
+			expectedConstants: []interface{}{1, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpTrue),
+				// 0007
+				code.Make(code.OpJumpFalse, 26),
+				// 0010
+				code.Make(code.OpGetGlobal, 0),
+				// 0013
+				code.Make(code.OpConstant, 1),
+				// 0016
+				code.Make(code.OpAdd),
+				// 0017
+				code.Make(code.OpSetLocal, 0),
+				// 0019
+				code.Make(code.OpGetLocal, 0),
+				// 0021
+				code.Make(code.OpClose, 0),
+				// 0023
+				code.Make(code.OpJump, 27),
+				// 0026
+				code.Make(code.OpNull),
+				// 0027
+				code.Make(code.OpPop),
+				// 0028
+				code.Make(code.OpGetGlobal, 0),
+				// 0031
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			func() {
+				while (true) { let a = 1; };
+				let b = 2;
+				b
+			}
+			`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpTrue),
+					// 0001
+					code.Make(code.OpJumpFalse, 14),
+					// 0004
+					code.Make(code.OpConstant, 0),
+					// 0007
+					code.Make(code.OpSetLocal, 0),
+					// 0009
+					code.Make(code.OpClose, 0),
+					// 0011
+					code.Make(code.OpJump, 0),
+					// 0014
+					code.Make(code.OpNull),
+					// 0015
+					code.Make(code.OpPop),
+					// 0016
+					code.Make(code.OpConstant, 1),
+					// 0019
+					code.Make(code.OpSetLocal, 0),
+					// 0021
+					code.Make(code.OpGetLocal, 0),
+					// 0023
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBlockScopeErrors(t *testing.T) {
+	compiler := New()
+	err := compiler.Compile(parse(`if (true) { let a = 1; }; a;`))
+	if err == nil || err.Error() != "undefined variable a" {
+		t.Errorf("expected block local to be out of scope. got=%v", err)
+	}
+
+	// a failed block leaves its scope, so the table can be used again
+	symbolTable := NewSymbolTable()
+	compiler = NewWithState(symbolTable, []object.Object{})
+	err = compiler.Compile(parse(`if (true) { let b = 1; nope }`))
+	if err == nil {
+		t.Fatalf("expected an error for an undefined variable")
+	}
+
+	symbol := symbolTable.Define("c")
+	if symbol.Scope != GlobalScope {
+		t.Errorf("symbol table still in block scope. got=%s", symbol.Scope)
+	}
+}
+
 func TestFunctions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -806,7 +960,7 @@ func TestBuiltins(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
 					code.Make(code.OpArray, 0),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -838,7 +992,7 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -868,13 +1022,13 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -922,15 +1076,15 @@ func TestClosures(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpConstant, 2),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 4, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
 					code.Make(code.OpConstant, 1),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 5, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -961,7 +1115,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -990,7 +1144,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -999,7 +1153,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 2),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -1016,6 +1170,84 @@ func TestRecursiveFunctions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			let f = func(x) {
+				if (x) { return f(false); }
+				f(x) + 1;
+			};
+			`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpJumpFalse, 14),
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpFalse),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+					code.Make(code.OpNull),
+					code.Make(code.OpJump, 15),
+					code.Make(code.OpNull),
+					code.Make(code.OpPop),
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input: `
+			let f = func(x) {
+				switch (x) {
+					case 1 { f(2) }
+					default { len(x) }
+				}
+			};
+			f(1);
+			`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpEqual),
+					code.Make(code.OpJumpFalse, 18),
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpJump, 24),
+					code.Make(code.OpGetBuiltin, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	t.Helper()
 
@@ -1042,6 +1274,29 @@ func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	}
 }
 
+func TestDisassemble(t *testing.T) {
+	compiler := New()
+	err := compiler.Compile(parse(`let f = func(a) { a }; f(1);`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := "0000 OpClosure 0 0\n" +
+		"0004 OpSetGlobal 0\n" +
+		"0007 OpGetGlobal 0\n" +
+		"0010 OpConstant 1\n" +
+		"0013 OpCall 1\n" +
+		"0015 OpPop\n" +
+		"\nconstant 0, function f:\n" +
+		"0000 OpGetLocal 0\n" +
+		"0002 OpReturnValue\n"
+
+	disassembled := compiler.MakeBytecode().Disassemble(0)
+	if disassembled != expected {
+		t.Errorf("wrong disassembly.\nwant=%q\ngot= %q", expected, disassembled)
+	}
+}
+
 func parse(input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -0,0 +1,23 @@
+package compiler
+
+import (
+	"fmt"
+	"mylang/token"
+)
+
+// an error in the program found while compiling, along with the token
+// it was found at so it can be shown in the source
+type CompileError struct {
+	Message string
+	Token token.Token
+}
+
+func (e *CompileError) Error() string {
+	return e.Message
+}
+
+// returns a new compile error at the token. Uses the same interface
+// as Sprintf
+func newCompileError(tok token.Token, format string, a ...interface{}) error {
+	return &CompileError{Message: fmt.Sprintf(format, a...), Token: tok}
+}
@@ -1,5 +1,7 @@
 package compiler
 
+import "sort"
+
 type SymbolScope string
 
 type Symbol struct {
@@ -14,6 +16,14 @@ type SymbolTable struct {
 
 	store map[string]Symbol
 	definitions int
+
+	// blocks currently open in this table, innermost last. Names defined
+	// in a block are locals that go out of scope when the block ends, and
+	// their slots are reused by the blocks that follow
+	blocks []map[string]Symbol
+	blockStarts []int
+	locals int
+	maxLocals int
 }
 
 const (
@@ -36,30 +46,75 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
-// defines a symbol for the current symbol table
+// defines a symbol for the current symbol table. Names defined outside
+// of any function or block are globals, everything else gets a local slot
 func (s *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{
-		Name: name,
-		Index: s.definitions,
-	}
+	symbol := Symbol{Name: name}
 
-	if s.Outer == nil {
+	if s.atGlobalLevel() {
 		symbol.Scope = GlobalScope
+		symbol.Index = s.definitions
+		s.definitions++
 	} else {
 		symbol.Scope = LocalScope
+		symbol.Index = s.locals
+		s.locals++
+		s.maxLocals = max(s.maxLocals, s.locals)
 	}
 
-	s.store[name] = symbol
-	s.definitions++
+	s.currentStore()[name] = symbol
 	return symbol
 }
 
-// checks all scopes for the given symbol. If the symbol is not in the
-// current scope, checks the outer scopes recursively. If the object
+// opens a new block scope nested in the current one
+func (s *SymbolTable) EnterBlock() {
+	s.blocks = append(s.blocks, make(map[string]Symbol))
+	s.blockStarts = append(s.blockStarts, s.locals)
+}
+
+// closes the innermost block scope and returns the first local slot
+// it used along with the number of locals it defined
+func (s *SymbolTable) LeaveBlock() (int, int) {
+	start := s.blockStarts[len(s.blockStarts) - 1]
+	defined := s.locals - start
+
+	s.blocks = s.blocks[:len(s.blocks) - 1]
+	s.blockStarts = s.blockStarts[:len(s.blockStarts) - 1]
+	s.locals = start
+
+	return start, defined
+}
+
+// the number of local slots a frame for this table needs
+func (s *SymbolTable) NumLocals() int {
+	return s.maxLocals
+}
+
+// reports whether names defined now would be globals
+func (s *SymbolTable) atGlobalLevel() bool {
+	return s.Outer == nil && len(s.blocks) == 0
+}
+
+func (s *SymbolTable) currentStore() map[string]Symbol {
+	if len(s.blocks) > 0 {
+		return s.blocks[len(s.blocks) - 1]
+	}
+	return s.store
+}
+
+// checks all scopes for the given symbol, starting from the innermost
+// block. If the symbol is not in the current table, checks the outer
+// scopes recursively. If the object
 // exists in an outer scope, if it is a global or a builtin returns it.
 // In the case of a local scope symbol from an outer symboltable, defines
 // it as a free variable for the current symbol table 
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if obj, ok := s.blocks[i][name]; ok {
+			return obj, ok
+		}
+	}
+
 	obj, ok := s.store[name]
 	if !ok && s.Outer != nil {
 		obj, ok = s.Outer.Resolve(name)
@@ -77,6 +132,33 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	return obj, ok
 }
 
+// returns the globals defined in the table, in the order they were defined
+func (s *SymbolTable) Globals() []Symbol {
+	globals := []Symbol{}
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope {
+			globals = append(globals, symbol)
+		}
+	}
+
+	sort.Slice(globals, func(i, j int) bool {
+		return globals[i].Index < globals[j].Index
+	})
+	return globals
+}
+
+// returns a copy of a global symbol table that can be defined into
+// without changing the original, used by the repl to compile input that
+// should not be run, like the :bytecode command, or that may not compile
+func (s *SymbolTable) Clone() *SymbolTable {
+	clone := NewSymbolTable()
+	for name, symbol := range s.store {
+		clone.store[name] = symbol
+	}
+	clone.definitions = s.definitions
+	return clone
+}
+
 // assigns a symbol to a builtin 
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{
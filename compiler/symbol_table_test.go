@@ -220,3 +220,93 @@ func TestShadowingFunctionName(t *testing.T) {
 			expected.Name, expected, result)
 	}
 }
+
+func TestSymbolTableBlocks(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	global.EnterBlock()
+	b := global.Define("b")
+	expected := Symbol{Name: "b", Scope: LocalScope, Index: 0}
+	if b != expected {
+		t.Errorf("expected b=%+v, got=%+v", expected, b)
+	}
+
+	global.EnterBlock()
+	a := global.Define("a")
+	expected = Symbol{Name: "a", Scope: LocalScope, Index: 1}
+	if a != expected {
+		t.Errorf("expected a=%+v, got=%+v", expected, a)
+	}
+
+	result, _ := global.Resolve("a")
+	if result != expected {
+		t.Errorf("expected a to resolve to %+v, got=%+v", expected, result)
+	}
+
+	start, defined := global.LeaveBlock()
+	if start != 1 || defined != 1 {
+		t.Errorf("wrong inner block. start=%d, defined=%d", start, defined)
+	}
+
+	start, defined = global.LeaveBlock()
+	if start != 0 || defined != 1 {
+		t.Errorf("wrong outer block. start=%d, defined=%d", start, defined)
+	}
+
+	result, _ = global.Resolve("a")
+	expected = Symbol{Name: "a", Scope: GlobalScope, Index: 0}
+	if result != expected {
+		t.Errorf("expected a to resolve to %+v, got=%+v", expected, result)
+	}
+
+	_, ok := global.Resolve("b")
+	if ok {
+		t.Errorf("b resolvable outside of its block")
+	}
+
+	// slots are reused by the next block
+	global.EnterBlock()
+	c := global.Define("c")
+	expected = Symbol{Name: "c", Scope: LocalScope, Index: 0}
+	if c != expected {
+		t.Errorf("expected c=%+v, got=%+v", expected, c)
+	}
+	global.LeaveBlock()
+
+	if global.NumLocals() != 2 {
+		t.Errorf("wrong number of locals. want=2, got=%d", global.NumLocals())
+	}
+}
+
+func TestSymbolTableGlobalsAndClone(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("b")
+	global.Define("a")
+
+	clone := global.Clone()
+	clone.Define("c")
+
+	expected := []Symbol{
+		{Name: "b", Scope: GlobalScope, Index: 0},
+		{Name: "a", Scope: GlobalScope, Index: 1},
+	}
+
+	globals := global.Globals()
+	if len(globals) != len(expected) {
+		t.Fatalf("wrong number of globals. want=%d, got=%d", len(expected), len(globals))
+	}
+	for i, symbol := range expected {
+		if globals[i] != symbol {
+			t.Errorf("wrong global. want=%+v, got=%+v", symbol, globals[i])
+		}
+	}
+
+	if len(clone.Globals()) != 3 {
+		t.Errorf("clone should have 3 globals. got=%d", len(clone.Globals()))
+	}
+	if _, ok := clone.Resolve("len"); !ok {
+		t.Errorf("clone is missing the builtins")
+	}
+}
@@ -0,0 +1,279 @@
+// runs programs on both the evaluator and the vm and reports where the
+// two engines disagree, or disagree with what the program expects
+package conformance
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"mylang/ast"
+	"mylang/compiler"
+	"mylang/evaluator"
+	"mylang/lexer"
+	"mylang/object"
+	"mylang/parser"
+	"mylang/token"
+	"mylang/vm"
+)
+
+//go:embed corpus/*.ml
+var corpus embed.FS
+
+// a program of the corpus along with what it expects, written as
+// comments in the program
+//
+//	// output: 3      a line the program prints, in order
+//	// result: [1, 2] the value of the last expression
+//	// error: by zero  the program fails with an error containing the text
+type Case struct {
+	Name string
+	Source string
+	Output []string
+	Result string
+	HasResult bool
+	Error string
+	HasError bool
+}
+
+// reads the expectations of a program from its line comments
+func NewCase(name string, source string) *Case {
+	c := &Case{Name: name, Source: source, Output: []string{}}
+
+	l := lexer.NewWithComments(source)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.COMMENT || !strings.HasPrefix(tok.Literal, "//") {
+			continue
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(tok.Literal, "//"))
+		annotation, value, ok := strings.Cut(comment, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch annotation {
+		case "output":
+			c.Output = append(c.Output, value)
+		case "result":
+			c.Result = value
+			c.HasResult = true
+		case "error":
+			c.Error = value
+			c.HasError = true
+		}
+	}
+
+	return c
+}
+
+// what running a program on one engine did. Result is the inspected
+// value of the last expression, empty when the program ends with
+// another kind of statement
+type Outcome struct {
+	Output []string
+	Result string
+	Error string
+}
+
+func (o Outcome) failed() bool {
+	return o.Error != ""
+}
+
+// runs the program on both engines and returns every way they disagree
+// with each other or with the expectations. No failures means the case
+// passes
+func (c *Case) Run() []string {
+	p := parser.New(lexer.New(c.Source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return []string{"does not parse: " + strings.Join(p.Errors(), "; ")}
+	}
+
+	eval := capture(func() (object.Object, error) {
+		result := evaluator.Evaluate(program, object.NewEnvironment())
+		if errObj, ok := result.(*object.Error); ok {
+			return nil, fmt.Errorf("%s", errObj.Message)
+		}
+		if exit, ok := result.(*object.Exit); ok {
+			return nil, exit
+		}
+		return result, nil
+	})
+
+	machine := capture(func() (object.Object, error) {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return nil, err
+		}
+
+		machine := vm.New(comp.MakeBytecode())
+		if err := machine.Run(); err != nil {
+			return nil, err
+		}
+		return machine.LastPoppedStackElement(), nil
+	})
+
+	if !endsWithExpression(program) {
+		eval.Result = ""
+		machine.Result = ""
+	}
+
+	failures := []string{}
+	failures = append(failures, c.check("eval", eval)...)
+	failures = append(failures, c.check("vm", machine)...)
+
+	if eval.failed() != machine.failed() {
+		failures = append(failures, fmt.Sprintf(
+			"engines disagree on failing: eval error=%q, vm error=%q", eval.Error, machine.Error))
+	}
+	if !equalLines(eval.Output, machine.Output) {
+		failures = append(failures, fmt.Sprintf(
+			"engines disagree on output: eval=%q, vm=%q", eval.Output, machine.Output))
+	}
+	if !eval.failed() && !machine.failed() && eval.Result != machine.Result {
+		failures = append(failures, fmt.Sprintf(
+			"engines disagree on result: eval=%s, vm=%s", eval.Result, machine.Result))
+	}
+
+	return failures
+}
+
+// compares an outcome with the expectations of the case
+func (c *Case) check(engine string, outcome Outcome) []string {
+	failures := []string{}
+
+	if c.HasError {
+		if !outcome.failed() {
+			failures = append(failures, fmt.Sprintf("%s: expected error %q, got none", engine, c.Error))
+		} else if !strings.Contains(outcome.Error, c.Error) {
+			failures = append(failures, fmt.Sprintf("%s: expected error containing %q, got %q",
+				engine, c.Error, outcome.Error))
+		}
+	} else if outcome.failed() {
+		failures = append(failures, fmt.Sprintf("%s: unexpected error %q", engine, outcome.Error))
+	}
+
+	if !equalLines(outcome.Output, c.Output) {
+		failures = append(failures, fmt.Sprintf("%s: expected output %q, got %q",
+			engine, c.Output, outcome.Output))
+	}
+
+	if c.HasResult && !outcome.failed() && outcome.Result != c.Result {
+		failures = append(failures, fmt.Sprintf("%s: expected result %s, got %s",
+			engine, c.Result, outcome.Result))
+	}
+
+	return failures
+}
+
+// runs one engine with the output of puts captured. A panic inside the
+// engine counts as an error so one broken case does not stop the rest
+func capture(run func() (object.Object, error)) (outcome Outcome) {
+	var out bytes.Buffer
+	stdout := object.Stdout
+	object.Stdout = &out
+
+	defer func() {
+		object.Stdout = stdout
+
+		if r := recover(); r != nil {
+			outcome.Error = fmt.Sprintf("panic: %v", r)
+		}
+		outcome.Output = splitLines(out.String())
+	}()
+
+	result, err := run()
+	if err != nil {
+		outcome.Error = err.Error()
+	} else if result != nil {
+		outcome.Result = result.Inspect()
+	}
+
+	return outcome
+}
+
+func endsWithExpression(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	_, ok := program.Statements[len(program.Statements) - 1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// the cases built into mylang, sorted by name
+func Corpus() ([]*Case, error) {
+	entries, err := corpus.ReadDir("corpus")
+	if err != nil {
+		return nil, err
+	}
+
+	cases := []*Case{}
+	for _, entry := range entries {
+		source, err := corpus.ReadFile("corpus/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, NewCase(entry.Name(), string(source)))
+	}
+
+	return cases, nil
+}
+
+// the cases in the given files, or in the .ml files of the given
+// directories
+func Load(paths []string) ([]*Case, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*.ml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	cases := []*Case{}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, NewCase(file, string(source)))
+	}
+
+	return cases, nil
+}
@@ -0,0 +1,89 @@
+package conformance
+
+import (
+	"testing"
+)
+
+func TestCorpus(t *testing.T) {
+	cases, err := Corpus()
+	if err != nil {
+		t.Fatalf("could not read the corpus: %s", err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("the corpus is empty")
+	}
+
+	for _, c := range cases {
+		for _, failure := range c.Run() {
+			t.Errorf("%s: %s", c.Name, failure)
+		}
+	}
+}
+
+func TestNewCase(t *testing.T) {
+	c := NewCase("case.ml", `
+	puts(1); // output: 1
+	// output: two words
+	// result: [1, 2]
+	// error: by zero
+	// a comment: that is not an annotation
+	`)
+
+	if len(c.Output) != 2 || c.Output[0] != "1" || c.Output[1] != "two words" {
+		t.Errorf("wrong output. got=%q", c.Output)
+	}
+	if !c.HasResult || c.Result != "[1, 2]" {
+		t.Errorf("wrong result. got=%q", c.Result)
+	}
+	if !c.HasError || c.Error != "by zero" {
+		t.Errorf("wrong error. got=%q", c.Error)
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []string
+	}{
+		{
+			"puts(1); 2 // output: 1\n// result: 2",
+			[]string{},
+		},
+		{
+			"puts(2); // output: 1",
+			[]string{
+				`eval: expected output ["1"], got ["2"]`,
+				`vm: expected output ["1"], got ["2"]`,
+			},
+		},
+		{
+			"missing",
+			[]string{
+				`eval: unexpected error "identifier not found: missing"`,
+				`vm: unexpected error "undefined variable missing"`,
+			},
+		},
+		{
+			"1 // error: boom",
+			[]string{
+				`eval: expected error "boom", got none`,
+				`vm: expected error "boom", got none`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		failures := NewCase("case.ml", tt.source).Run()
+
+		if len(failures) != len(tt.expected) {
+			t.Errorf("wrong number of failures for %q. want=%d, got=%d (%q)",
+				tt.source, len(tt.expected), len(failures), failures)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if failures[i] != expected {
+				t.Errorf("wrong failure. want=%q, got=%q", expected, failures[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// integer and float arithmetic with the usual precedence
+puts(1 + 2 * 3);
+// output: 7
+puts(10 / 3, 10 % 3, -7 / 2);
+// output: 3
+// output: 1
+// output: -3
+puts(1.5 * 2.0);
+// output: 3.000000
+(2 + 3) * 4 - 1
+// result: 19
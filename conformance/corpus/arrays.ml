@@ -0,0 +1,11 @@
+let a = [1, 2, 3];
+puts(first(a), last(a), rest(a));
+// output: 1
+// output: 3
+// output: [2, 3]
+puts(push(a, 4));
+// output: [1, 2, 3, 4]
+puts(a[0] + a[2]);
+// output: 4
+a[5]
+// result: null
@@ -0,0 +1,31 @@
+// strings never change, builtins that would change one return a new one
+let word = "cat";
+let changed = assign(word, 0, "b");
+puts(word, changed, pop(word));
+// output: cat
+// output: bat
+// output: ca
+
+// a literal in a loop is the same value on every pass
+let i = 0;
+let seen = [];
+while (i < 3) {
+    let s = "ab";
+    seen = push(seen, s);
+    s = assign(s, 0, "x");
+    i = i + 1;
+}
+puts(seen);
+// output: [ab, ab, ab]
+
+// builders are changed in place
+let b = builder();
+let j = 0;
+while (j < 3) {
+    append(b, j, ",");
+    j = j + 1;
+}
+puts(len(b));
+// output: 6
+string(b)
+// result: 0,1,2,
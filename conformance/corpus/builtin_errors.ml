@@ -0,0 +1,5 @@
+puts("before");
+// output: before
+first("x");
+puts("after");
+// error: must be ARRAY
@@ -0,0 +1,10 @@
+puts(type(1), type("a"), type([]), type(1.5));
+// output: INTEGER
+// output: STRING
+// output: ARRAY
+// output: FLOAT
+puts(int("42") + 1, string(12) + "3");
+// output: 43
+// output: 123
+len([1, 2, 3]) + len("ab")
+// result: 5
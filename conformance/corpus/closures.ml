@@ -0,0 +1,26 @@
+let adder = func(x) { func(y) { x + y } };
+let addTwo = adder(2);
+puts(addTwo(3));
+// output: 5
+
+let counter = func() {
+    let count = 0;
+    func() { count = count + 1; count };
+};
+let next = counter();
+next();
+next();
+puts(next());
+// output: 3
+
+// closures made together share the variables they capture
+let pair = func() {
+    let value = 0;
+    let set = func(v) { value = v; };
+    let get = func() { value };
+    [set, get];
+};
+let fs = pair();
+fs[0](42);
+fs[1]()
+// result: 42
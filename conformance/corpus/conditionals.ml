@@ -0,0 +1,8 @@
+let max = func(a, b) { if (a > b) { a } else { b } };
+puts(max(3, 7), max(9, 2));
+// output: 7
+// output: 9
+puts(if (false) { 1 });
+// output: null
+if (1 < 2 and 2 < 3 or false) { "both" } else { "neither" }
+// result: both
@@ -0,0 +1,22 @@
+// arrays and hashes compare by their contents
+puts([1, [2, 3]] == [1, [2, 3]]);
+// output: true
+puts({"a": [1]} == {"a": [1]}, {"a": 1} != {"a": 2});
+// output: true
+// output: true
+puts([1] == ["1"], 1 == "1");
+// output: false
+// output: false
+
+// switch matches cases the same way
+let kind = func(value) {
+    switch (value) {
+        case [0, 0] { "origin" }
+        case {"x": 1} { "hash" }
+        default { "other" }
+    }
+};
+puts(kind([0, 0]), kind({"x": 1}), kind([0]));
+// output: origin
+// output: hash
+// output: other
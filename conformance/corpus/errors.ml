@@ -0,0 +1,6 @@
+puts("before");
+// output: before
+let f = func(x) { x };
+f(1, 2);
+puts("after");
+// error: wrong number of arguments
@@ -0,0 +1,15 @@
+// floats are keyed by their exact value, and never by the integer they
+// equal, just as 1 == 1.0 is false. -0.0 is the same key as 0.0
+let h = {0.0: "zero", 0.0000001: "tiny", 1: "int", 1.0: "float"};
+puts(len(keys(h)));
+// output: 4
+puts(h[0.0], h[0.0000001]);
+// output: zero
+// output: tiny
+puts(h[1], h[1.0]);
+// output: int
+// output: float
+puts(h[0.0 * (0.0 - 1.0)]);
+// output: zero
+h[2.0]
+// result: null
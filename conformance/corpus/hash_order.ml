@@ -0,0 +1,16 @@
+// hashes keep their keys in the order they were first set
+let h = {"b": 2, "a": 1, "c": 3};
+puts(h);
+// output: {b: 2, a: 1, c: 3}
+puts(keys(h));
+// output: [b, a, c]
+puts(values(h));
+// output: [2, 1, 3]
+
+delete(h, "a");
+assign(h, "b", 20);
+assign(h, "a", 10);
+puts(items(h));
+// output: [[b, 20], [c, 3], [a, 10]]
+h
+// result: {b: 20, c: 3, a: 10}
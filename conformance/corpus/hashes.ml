@@ -0,0 +1,11 @@
+let h = {"one": 1};
+puts(h["one"]);
+// output: 1
+puts(h["two"]);
+// output: null
+let k = {true: "yes", 2: "two"};
+puts(k[true], k[1 + 1]);
+// output: yes
+// output: two
+h["one"] + 1
+// result: 2
@@ -0,0 +1,12 @@
+// ${} puts the value of any expression into a string
+let name = "world";
+let n = 3;
+puts("hello ${name}, ${n} + 1 = ${n + 1}");
+// output: hello world, 3 + 1 = 4
+puts("list ${[1, 2]}, hash ${{"a": n}}, ${"nested ${n * 2}"}");
+// output: list [1, 2], hash {a: 3}, nested 6
+puts("not \${interpolated}");
+// output: not ${interpolated}
+let greet = func(who) { "hi ${who}!" };
+greet(greet("me"))
+// result: hi hi me!!
@@ -0,0 +1,8 @@
+let fib = func(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+puts(fib(15));
+// output: 610
+
+// tail calls run in constant stack space on both engines
+let loop = func(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + n) } };
+loop(100000, 0)
+// result: 5000050000
@@ -0,0 +1,18 @@
+let x = 1;
+if (true) {
+    let x = 2;
+    puts(x);
+}
+// output: 2
+puts(x);
+// output: 1
+let i = 0;
+while (i < 2) {
+    let y = i * 10;
+    i = i + 1;
+    puts(y);
+}
+// output: 0
+// output: 10
+x
+// result: 1
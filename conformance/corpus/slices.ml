@@ -0,0 +1,33 @@
+// slices take a range of an array or a string, with bounds that are
+// left out covering the whole value and negative bounds counting from
+// the end
+let a = [1, 2, 3, 4, 5];
+puts(a[1:3], a[:2], a[3:], a[-2:]);
+// output: [2, 3]
+// output: [1, 2]
+// output: [4, 5]
+// output: [4, 5]
+
+// a step skips elements, and a negative one goes backwards
+puts(a[::2], a[::-1], a[3:0:-1]);
+// output: [1, 3, 5]
+// output: [5, 4, 3, 2, 1]
+// output: [4, 3, 2]
+
+// bounds past the ends are moved to them
+puts(a[-10:10], a[4:1]);
+// output: [1, 2, 3, 4, 5]
+// output: []
+
+// strings are sliced by code point
+let s = "héllo";
+puts(s[1:3], s[::-1], s[-3:]);
+// output: él
+// output: olléh
+// output: llo
+
+// slicing copies, so the original is left as it was
+let b = a[:];
+b = push(b, 6);
+len(a)
+// result: 5
@@ -0,0 +1,18 @@
+// the string builtins give the same results on both engines
+let csv = " name, age ,city ";
+let fields = split(trim(csv), ",");
+puts(len(fields), join(fields, "|"));
+// output: 3
+// output: name| age |city
+puts(upper(substr("héllo", 0, 2)), indexOf("héllo", "llo"));
+// output: HÉ
+// output: 2
+puts(padLeft(string(42), 5, "0"), repeat("-", 3));
+// output: 00042
+// output: ---
+puts(contains(csv, "age"), startsWith(csv, " n"), endsWith(csv, "y"));
+// output: true
+// output: true
+// output: false
+lines(replace("a;b;c", ";", "\n"))
+// result: [a, b, c]
@@ -0,0 +1,15 @@
+// escapes, raw strings and multi-line strings
+puts("tab\tquote\" \x41é");
+// output: tab	quote" Aé
+puts(`no \t escapes`);
+// output: no \t escapes
+let poem = func() {
+    """
+    roses
+      are red
+    """
+};
+puts(poem() == "roses\n  are red");
+// output: true
+len("\0")
+// result: 1
@@ -0,0 +1,7 @@
+let greeting = "hello" + " " + "world";
+puts(greeting);
+// output: hello world
+puts(len(greeting));
+// output: 11
+greeting == "hello world"
+// result: true
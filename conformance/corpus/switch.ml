@@ -0,0 +1,18 @@
+let describe = func(n) {
+    switch (n) {
+        case 1 { "one" }
+        case 2 { "two" }
+        default { "many" }
+    }
+};
+puts(describe(1), describe(2), describe(3));
+// output: one
+// output: two
+// output: many
+
+// a string is never equal to an integer, even if they print the same
+switch ("1") {
+    case 1 { "integer" }
+    default { "string" }
+}
+// result: string
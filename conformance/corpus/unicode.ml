@@ -0,0 +1,24 @@
+// strings are measured and indexed by code point
+let s = "héllo, 世界";
+puts(len(s));
+// output: 9
+puts(s[1], s[7], s[8]);
+// output: é
+// output: 世
+// output: 界
+puts(s[9]);
+// output: null
+puts(assign(s, 1, "e"), pop(s));
+// output: hello, 世界
+// output: héllo, 世
+
+// bytes and runes give the encoding and the code points
+puts(bytes("é"), runes("é"));
+// output: [195, 169]
+// output: [233]
+// builders are measured the same way
+puts(len(append(builder(), "é", s)));
+// output: 10
+
+len(bytes(s))
+// result: 14
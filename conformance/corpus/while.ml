@@ -0,0 +1,9 @@
+let i = 0;
+let total = 0;
+while (i < 5) {
+    i = i + 1;
+    if (i == 3) { total = total + 100; };
+    total = total + i;
+}
+puts(total);
+// output: 115
@@ -0,0 +1,135 @@
+package diagnostic
+
+import (
+	"errors"
+	"fmt"
+	"mylang/compiler"
+	"mylang/object"
+	"mylang/parser"
+	"mylang/vm"
+)
+
+type Severity string
+
+const (
+	WARNING Severity = "warning"
+	ERROR   Severity = "error"
+)
+
+// the kind of problem a diagnostic reports, so tools can filter or
+// count them without matching on the message
+type Kind string
+
+const (
+	SYNTAX_ERROR  Kind = "syntax-error"
+	COMPILE_ERROR Kind = "compile-error"
+	RUNTIME_ERROR Kind = "runtime-error"
+)
+
+// a problem found in a program. Line and Column are where it starts in
+// the input, and Length is how many characters of the line it covers.
+// A Line of 0 means the problem has no position in the source, like
+// most runtime errors. Notes hold extra hints shown under the problem
+type Diagnostic struct {
+	Severity Severity
+	Kind Kind
+	Message string
+	Line int
+	Column int
+	Length int
+	Notes []string
+}
+
+// formats the diagnostic as "line:column: severity: message"
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+// returns the diagnostics with every warning turned into an error, used
+// for the -Werror option
+func PromoteWarnings(diagnostics []Diagnostic) []Diagnostic {
+	promoted := make([]Diagnostic, len(diagnostics))
+
+	for i, d := range diagnostics {
+		d.Severity = ERROR
+		promoted[i] = d
+	}
+
+	return promoted
+}
+
+// reports whether any of the diagnostics is an error
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// converts the syntax errors of the parser, with their suggested fixes
+// as notes
+func FromParseErrors(parseErrors []*parser.ParseError) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, err := range parseErrors {
+		d := Diagnostic{
+			Severity: ERROR,
+			Kind: SYNTAX_ERROR,
+			Message: err.Message,
+			Line: err.Line,
+			Column: err.Column,
+			Length: len([]rune(err.Found.Literal)),
+		}
+		if err.Suggestion != "" {
+			d.Notes = []string{err.Suggestion}
+		}
+
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
+
+// converts an error the evaluator stopped with, pointing at the
+// expression it was raised by when it is known
+func FromErrorObject(err *object.Error) Diagnostic {
+	return Diagnostic{
+		Severity: ERROR,
+		Kind: RUNTIME_ERROR,
+		Message: err.Message,
+		Line: err.Position.Line,
+		Column: err.Position.Column,
+		Length: err.Position.Length,
+	}
+}
+
+// converts an error from compiling or running a program. Compile errors
+// point at the token they were found at, and errors of the vm at the
+// source of the instruction that failed, when it is known
+func FromError(err error, kind Kind, notes ...string) Diagnostic {
+	d := Diagnostic{
+		Severity: ERROR,
+		Kind: kind,
+		Message: err.Error(),
+		Notes: notes,
+	}
+
+	var compileError *compiler.CompileError
+	if errors.As(err, &compileError) {
+		d.Kind = COMPILE_ERROR
+		d.Line = compileError.Token.Line
+		d.Column = compileError.Token.Column
+		d.Length = len([]rune(compileError.Token.Literal))
+	}
+
+	var runtimeError *vm.RuntimeError
+	if errors.As(err, &runtimeError) && runtimeError.Position.Line > 0 {
+		d.Line = runtimeError.Position.Line
+		d.Column = runtimeError.Position.Column
+		d.Length = runtimeError.Position.Length
+	}
+
+	return d
+}
@@ -0,0 +1,193 @@
+package diagnostic
+
+import (
+	"bytes"
+	"fmt"
+	"mylang/compiler"
+	"mylang/evaluator"
+	"mylang/lexer"
+	"mylang/object"
+	"mylang/parser"
+	"mylang/vm"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	source := "let x = 1;\n\tlet total = x +;\n"
+
+	tests := []struct {
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			Diagnostic{
+				Severity: WARNING,
+				Message: "total is defined but never used",
+				Line: 2,
+				Column: 6,
+				Length: 5,
+			},
+			"main.ml:2:6: warning: total is defined but never used\n" +
+				"    2 | \tlet total = x +;\n" +
+				"      | \t    ^~~~~\n",
+		},
+		{
+			Diagnostic{
+				Severity: ERROR,
+				Message: "no prefix parse function for ; found",
+				Line: 2,
+				Column: 17,
+				Length: 1,
+				Notes: []string{"an expression is missing before ';'"},
+			},
+			"main.ml:2:17: error: no prefix parse function for ; found\n" +
+				"    2 | \tlet total = x +;\n" +
+				"      | \t               ^\n" +
+				"      = note: an expression is missing before ';'\n",
+		},
+		{
+			Diagnostic{
+				Severity: ERROR,
+				Message: "stack overflow",
+				Notes: []string{"raised in f"},
+			},
+			"main.ml: error: stack overflow\n" +
+				"      = note: raised in f\n",
+		},
+	}
+
+	renderer := NewRenderer("main.ml", source, false)
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		renderer.Render(&out, tt.diagnostic)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output.\nwant=%q\ngot= %q", tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	var out bytes.Buffer
+
+	renderer := NewRenderer("main.ml", "x", true)
+	renderer.Render(&out, Diagnostic{Severity: WARNING, Message: "m", Line: 1, Column: 1})
+
+	expected := bold + "main.ml:1:1" + reset + ": " +
+		bold + yellow + "warning" + reset + ": " + bold + "m" + reset + "\n" +
+		cyan + "    1 |" + reset + " x\n" +
+		cyan + "      |" + reset + " " + bold + yellow + "^" + reset + "\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot= %q", expected, out.String())
+	}
+}
+
+func TestFromParseErrors(t *testing.T) {
+	p := parser.New(lexer.New("let x = 5 +;\nlet 3 = 1;"))
+	p.ParseProgram()
+
+	diagnostics := FromParseErrors(p.ParseErrors())
+
+	expected := []string{
+		"1:12: error: no prefix parse function for ; found",
+		"2:5: error: expected next token to be IDENT, got INT instead",
+	}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. want=%d, got=%d",
+			len(expected), len(diagnostics))
+	}
+
+	for i, e := range expected {
+		if diagnostics[i].String() != e {
+			t.Errorf("wrong diagnostic. want=%q, got=%q", e, diagnostics[i].String())
+		}
+		if diagnostics[i].Kind != SYNTAX_ERROR || len(diagnostics[i].Notes) != 1 {
+			t.Errorf("wrong kind or notes. got=%+v", diagnostics[i])
+		}
+	}
+}
+
+func TestFromError(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parser.New(lexer.New("let a = 1;\na + missing;")).ParseProgram())
+	if err == nil {
+		t.Fatalf("expected compile error")
+	}
+
+	d := FromError(err, RUNTIME_ERROR)
+	if d.Kind != COMPILE_ERROR || d.Line != 2 || d.Column != 5 || d.Length != 7 {
+		t.Errorf("wrong compile error diagnostic. got=%+v", d)
+	}
+
+	d = FromError(fmt.Errorf("stack overflow"), RUNTIME_ERROR, "raised in f")
+	if d.Kind != RUNTIME_ERROR || d.Line != 0 || d.Notes[0] != "raised in f" {
+		t.Errorf("wrong runtime error diagnostic. got=%+v", d)
+	}
+}
+
+// runtime errors of both engines point at the expression that failed
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		line int
+		column int
+		length int
+	}{
+		{"let a = 1;\nfirst(a);", 2, 1, 5},
+		{"let f = func(x) {\n  x + true\n};\nf(1);", 2, 5, 1},
+		{"let a = [1];\na[\"b\"];", 2, 2, 1},
+		{"let b = 2;\n-\"a\";", 2, 1, 1},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		machine := vm.New(comp.MakeBytecode())
+		err = machine.Run()
+		if err == nil {
+			t.Fatalf("expected a vm error for %q", tt.input)
+		}
+
+		d := FromError(err, RUNTIME_ERROR)
+		if d.Line != tt.line || d.Column != tt.column || d.Length != tt.length {
+			t.Errorf("wrong vm error position for %q. want=%d:%d+%d, got=%d:%d+%d",
+				tt.input, tt.line, tt.column, tt.length, d.Line, d.Column, d.Length)
+		}
+
+		result := evaluator.Evaluate(program, object.NewEnvironment())
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Fatalf("expected an evaluator error for %q. got=%T", tt.input, result)
+		}
+
+		d = FromErrorObject(errObj)
+		if d.Line != tt.line || d.Column != tt.column || d.Length != tt.length {
+			t.Errorf("wrong evaluator error position for %q. want=%d:%d+%d, got=%d:%d+%d",
+				tt.input, tt.line, tt.column, tt.length, d.Line, d.Column, d.Length)
+		}
+	}
+}
+
+func TestPromoteWarnings(t *testing.T) {
+	diagnostics := []Diagnostic{{Severity: WARNING, Message: "unused"}}
+
+	if HasErrors(diagnostics) {
+		t.Errorf("warnings should not count as errors")
+	}
+
+	promoted := PromoteWarnings(diagnostics)
+	if !HasErrors(promoted) || promoted[0].Severity != ERROR {
+		t.Errorf("warnings were not promoted to errors. got=%v", promoted)
+	}
+	if diagnostics[0].Severity != WARNING {
+		t.Errorf("promoting should not change the original diagnostics")
+	}
+}
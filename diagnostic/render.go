@@ -0,0 +1,110 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	reset  = "\033[0m"
+	bold   = "\033[1m"
+	red    = "\033[31m"
+	yellow = "\033[33m"
+	cyan   = "\033[36m"
+)
+
+// prints diagnostics along with the line of source they point at, ie
+//
+//	main.ml:3:7: error: expected next token to be ), got { instead
+//	    3 | if (x { puts(x) }
+//	      |       ^
+//	      = note: add the missing ")"
+//
+// Color turns on ANSI colors for terminals
+type Renderer struct {
+	Filename string
+	Color bool
+	lines []string
+}
+
+func NewRenderer(filename string, source string, color bool) *Renderer {
+	return &Renderer{
+		Filename: filename,
+		Color: color,
+		lines: strings.Split(source, "\n"),
+	}
+}
+
+// writes every diagnostic to out
+func (r *Renderer) RenderAll(out io.Writer, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		r.Render(out, d)
+	}
+}
+
+// writes the diagnostic to out. The source line is only shown when the
+// diagnostic has a position inside the source
+func (r *Renderer) Render(out io.Writer, d Diagnostic) {
+	severityColor := red
+	if d.Severity == WARNING {
+		severityColor = yellow
+	}
+
+	location := r.Filename
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", r.Filename, d.Line, d.Column)
+	}
+
+	fmt.Fprintf(out, "%s: %s: %s\n",
+		r.paint(bold, location),
+		r.paint(bold + severityColor, string(d.Severity)),
+		r.paint(bold, d.Message))
+
+	gutter := "      |"
+	if d.Line > 0 && d.Line <= len(r.lines) {
+		line := strings.TrimRight(r.lines[d.Line - 1], "\r")
+
+		fmt.Fprintf(out, "%s %s\n", r.paint(cyan, fmt.Sprintf("%5d |", d.Line)), line)
+		fmt.Fprintf(out, "%s %s%s\n", r.paint(cyan, gutter),
+			padding(line, d.Column), r.paint(bold + severityColor, underline(d.Length)))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(out, "%s %s\n", r.paint(cyan, "      ="), "note: " + note)
+	}
+}
+
+func (r *Renderer) paint(color string, text string) string {
+	if !r.Color {
+		return text
+	}
+	return color + text + reset
+}
+
+// the whitespace that lines the underline up with the column. Tabs in
+// the line are kept so the underline lines up however wide they are shown
+func padding(line string, column int) string {
+	var out strings.Builder
+
+	for i, char := range []rune(line) {
+		if i >= column - 1 {
+			break
+		}
+		if char == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+
+	return out.String()
+}
+
+// a caret under the first character followed by tildes under the rest
+func underline(length int) string {
+	if length < 1 {
+		length = 1
+	}
+	return "^" + strings.Repeat("~", length - 1)
+}
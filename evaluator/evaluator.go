@@ -3,37 +3,70 @@ package evaluator
 import (
 	"fmt"
 	"math"
+	"unicode/utf8"
 	"mylang/ast"
+	"mylang/code"
 	"mylang/object"
 	"mylang/token"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len":     object.GetBuiltinByName("len"),
-	"puts":    object.GetBuiltinByName("puts"),
-	"first":   object.GetBuiltinByName("first"),
-	"last":    object.GetBuiltinByName("last"),
-	"rest":    object.GetBuiltinByName("rest"),
-	"push":    object.GetBuiltinByName("push"),
-	"pop":     object.GetBuiltinByName("pop"),
-	"string":  object.GetBuiltinByName("string"),
-	"keys":    object.GetBuiltinByName("keys"),
-	"delete":  object.GetBuiltinByName("delete"),
-	"assign":  object.GetBuiltinByName("assign"),
-	"type":    object.GetBuiltinByName("type"),
-	"command": object.GetBuiltinByName("command"),
-	"open":    object.GetBuiltinByName("open"),
-	"close":   object.GetBuiltinByName("close"),
-	"read":    object.GetBuiltinByName("read"),
-	"write":   object.GetBuiltinByName("write"),
-	"remove":  object.GetBuiltinByName("remove"),
-	"args":    object.GetBuiltinByName("args"),
-	"wait":    object.GetBuiltinByName("wait"),
-	"int":     object.GetBuiltinByName("int"),
-	"float":   object.GetBuiltinByName("float"),
-	"rand":    object.GetBuiltinByName("rand"),
+	"len":        object.GetBuiltinByName("len"),
+	"puts":       object.GetBuiltinByName("puts"),
+	"first":      object.GetBuiltinByName("first"),
+	"last":       object.GetBuiltinByName("last"),
+	"rest":       object.GetBuiltinByName("rest"),
+	"push":       object.GetBuiltinByName("push"),
+	"pop":        object.GetBuiltinByName("pop"),
+	"string":     object.GetBuiltinByName("string"),
+	"keys":       object.GetBuiltinByName("keys"),
+	"delete":     object.GetBuiltinByName("delete"),
+	"assign":     object.GetBuiltinByName("assign"),
+	"type":       object.GetBuiltinByName("type"),
+	"command":    object.GetBuiltinByName("command"),
+	"open":       object.GetBuiltinByName("open"),
+	"close":      object.GetBuiltinByName("close"),
+	"read":       object.GetBuiltinByName("read"),
+	"write":      object.GetBuiltinByName("write"),
+	"remove":     object.GetBuiltinByName("remove"),
+	"args":       object.GetBuiltinByName("args"),
+	"wait":       object.GetBuiltinByName("wait"),
+	"int":        object.GetBuiltinByName("int"),
+	"float":      object.GetBuiltinByName("float"),
+	"rand":       object.GetBuiltinByName("rand"),
+	"exit":       object.GetBuiltinByName("exit"),
+	"values":     object.GetBuiltinByName("values"),
+	"items":      object.GetBuiltinByName("items"),
+	"builder":    object.GetBuiltinByName("builder"),
+	"append":     object.GetBuiltinByName("append"),
+	"bytes":      object.GetBuiltinByName("bytes"),
+	"runes":      object.GetBuiltinByName("runes"),
+	"split":      object.GetBuiltinByName("split"),
+	"join":       object.GetBuiltinByName("join"),
+	"trim":       object.GetBuiltinByName("trim"),
+	"trimLeft":   object.GetBuiltinByName("trimLeft"),
+	"trimRight":  object.GetBuiltinByName("trimRight"),
+	"replace":    object.GetBuiltinByName("replace"),
+	"contains":   object.GetBuiltinByName("contains"),
+	"startsWith": object.GetBuiltinByName("startsWith"),
+	"endsWith":   object.GetBuiltinByName("endsWith"),
+	"indexOf":    object.GetBuiltinByName("indexOf"),
+	"upper":      object.GetBuiltinByName("upper"),
+	"lower":      object.GetBuiltinByName("lower"),
+	"repeat":     object.GetBuiltinByName("repeat"),
+	"substr":     object.GetBuiltinByName("substr"),
+	"lines":      object.GetBuiltinByName("lines"),
+	"padLeft":    object.GetBuiltinByName("padLeft"),
+	"padRight":   object.GetBuiltinByName("padRight"),
 }
 
+// the maximum number of nested function calls before evaluation stops
+// with a recursion error instead of exhausting the go stack
+var MaxCallDepth int = 1024
+
+// names of the functions currently being applied, outermost first
+var callStack []string
+
 // recursively evaluates every kind of node in the ast
 func Evaluate(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
@@ -81,7 +114,7 @@ func Evaluate(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evaluatePrefixOperator(node.Token, right)
+		return markError(evaluatePrefixOperator(node.Token, right), node.Token)
 	
 	case *ast.InfixExpression:
 		left := Evaluate(node.Left, env)
@@ -92,7 +125,7 @@ func Evaluate(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evaluateInfixOperator(node.Token, left, right)
+		return markError(evaluateInfixOperator(node.Token, left, right), node.Token)
 	
 	case *ast.Identifier:
 		return evaluateIdentifier(node, env)
@@ -109,6 +142,13 @@ func Evaluate(node ast.Node, env *object.Environment) object.Object {
 	case *ast.BooleanLiteral:
 		return getBoolObject(node.Value)
 	
+	case *ast.InterpolatedString:
+		parts := evaluateExpressions(node.Parts, env)
+		if len(parts) == 1 && isError(parts[0]) {
+			return parts[0]
+		}
+		return object.Interpolate(parts)
+
 	case *ast.ArrayLiteral:
 		elements := evaluateExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -135,6 +175,7 @@ func Evaluate(node ast.Node, env *object.Environment) object.Object {
 			Parameters: node.Parameters,
 			Environment: env,
 			Body: node.Body,
+			Name: node.Name,
 		}
 	
 	case *ast.CallExpression:
@@ -148,7 +189,11 @@ func Evaluate(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		if fn, ok := function.(*object.Function); ok && node.Tail {
+			return &object.TailCall{Function: fn, Arguments: args}
+		}
+
+		return markError(applyFunction(function, args), callToken(node))
 	
 	case *ast.IndexExpression:
 		left := Evaluate(node.Left, env)
@@ -159,12 +204,47 @@ func Evaluate(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evaluateIndexExpression(left, index)
+		return markError(evaluateIndexExpression(left, index), node.Token)
+
+	case *ast.SliceExpression:
+		return markError(evaluateSliceExpression(node, env), node.Token)
+
+	// left by the parser in place of code it could not parse
+	case *ast.BadStatement:
+		return newError("invalid syntax at line %d, column %d",
+			node.Token.Line, node.Token.Column)
+	case *ast.BadExpression:
+		return newError("invalid syntax at line %d, column %d",
+			node.Token.Line, node.Token.Column)
 	}
 
 	return nil
 }
 
+// gives an error made by the expression at the token the position of the
+// token, unless it already has the position of an expression inside
+func markError(obj object.Object, tok token.Token) object.Object {
+	err, ok := obj.(*object.Error)
+	if !ok || err.Position.Line > 0 || tok.Line == 0 {
+		return obj
+	}
+
+	err.Position = code.Position{
+		Line: tok.Line,
+		Column: tok.Column,
+		Length: utf8.RuneCountInString(tok.Literal),
+	}
+	return err
+}
+
+// the token a call is marked with, the name of the function if it has one
+func callToken(node *ast.CallExpression) token.Token {
+	if name, ok := node.Function.(*ast.Identifier); ok {
+		return name.Token
+	}
+	return node.Token
+}
+
 // calls evaluate on every statment in a program. If it encounters
 // a returnValue then it stops evaluation there. Used by Evaluate 
 // function to evaluate a program node
@@ -180,7 +260,7 @@ func evaluateProgram(
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
-		case *object.Error:
+		case *object.Error, *object.Exit:
 			return result
 		}
 	}
@@ -203,7 +283,7 @@ func evaluateBlockStatement(
 		
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || isError(result) {
 				return result
 			}
 		}
@@ -213,10 +293,11 @@ func evaluateBlockStatement(
 }
 
 // used to check if an object is an error so functions know when to halt
-// their execution and return the error
+// their execution and return the error. A call to exit halts the same
+// way, all the way out of the program
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.EXIT_OBJ
 	}
 	return false
 }
@@ -309,10 +390,10 @@ func evaluateInfixOperator(
 		return evaluateStringInfixOperator(operator, left, right)
 	
 	case operator.Type == token.EQ:
-		return getBoolObject(left == right)
+		return getBoolObject(object.Equal(left, right))
 	
 	case operator.Type == token.NOT_EQ:
-		return getBoolObject(left != right)
+		return getBoolObject(!object.Equal(left, right))
 	
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
@@ -443,9 +524,9 @@ func evaluateIfExpression(
 		return condition
 	}
 	if isTruthy(condition) {
-		return Evaluate(ie.Consequence, env)
+		return Evaluate(ie.Consequence, object.NewEnclosedEnvironment(env))
 	} else if ie.Alternative != nil {
-		return Evaluate(ie.Alternative, env)
+		return Evaluate(ie.Alternative, object.NewEnclosedEnvironment(env))
 	} else {
 		return object.NULL
 	}
@@ -463,8 +544,8 @@ func evaluateWhileExpression(
 			return condition
 		}
 		if isTruthy(condition) {
-			returnValue := Evaluate(we.Body, env)
-			if returnValue.Type() == object.RETURN_VALUE_OBJ || returnValue.Type() == object.ERROR_OBJ {
+			returnValue := Evaluate(we.Body, object.NewEnclosedEnvironment(env))
+			if returnValue.Type() == object.RETURN_VALUE_OBJ || isError(returnValue) {
 				return returnValue
 			}
 		} else {
@@ -493,14 +574,16 @@ func evaluateSwitchExpression(
 			return out
 		}
 
-		if value.Type() == out.Type() && value.Inspect() == out.Inspect() {
-			return evaluateBlockStatement(choice.Body, env)
+		if object.Equal(value, out) {
+			return evaluateBlockStatement(choice.Body,
+				object.NewEnclosedEnvironment(env))
 		}
 	}
 
 	for _, choice := range se.Cases {
 		if choice.Default {
-			return evaluateBlockStatement(choice.Body, env)
+			return evaluateBlockStatement(choice.Body,
+				object.NewEnclosedEnvironment(env))
 		}
 	}
 
@@ -555,16 +638,42 @@ func extendFunctionEnvironment(
 
 // executes the given function object with the given arguments. Extends the 
 // environment with the given arguments, evaluates the function, and returns
-// the return value
+// the return value. If the function ends in a tail call, the call is made
+// here in a loop instead of deeper in the go stack
 func applyFunction(
 	fn object.Object,
 	args []object.Object,
 ) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnvironment(fn, args)
-		evaluated := Evaluate(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		name := functionName(fn)
+
+		// the main program counts as a call like the main frame of the vm
+		if len(callStack) + 1 >= MaxCallDepth {
+			return recursionError(name)
+		}
+
+		callStack = append(callStack, name)
+		defer func() { callStack = callStack[:len(callStack) - 1] }()
+
+		for {
+			if len(args) != len(fn.Parameters) {
+				return newError("wrong number of arguments: want=%d, got=%d",
+					len(fn.Parameters), len(args))
+			}
+
+			extendedEnv := extendFunctionEnvironment(fn, args)
+			evaluated := unwrapReturnValue(Evaluate(fn.Body, extendedEnv))
+
+			tailCall, ok := evaluated.(*object.TailCall)
+			if !ok {
+				return evaluated
+			}
+
+			fn = tailCall.Function
+			args = tailCall.Arguments
+			callStack[len(callStack) - 1] = functionName(fn)
+		}
 	
 	case *object.Builtin:
 		if result := fn.Function(args...); result != nil {
@@ -576,6 +685,27 @@ func applyFunction(
 	}
 }
 
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// error for a call that would go over the maximum call depth. Lists the
+// functions being applied starting with the one that was being called
+func recursionError(callee string) *object.Error {
+	names := []string{callee}
+
+	for i := len(callStack) - 1; i >= 0; i-- {
+		names = append(names, callStack[i])
+	}
+	names = append(names, "<main>")
+
+	return newError("maximum recursion depth exceeded: %s",
+		object.CallChain(names))
+}
+
 func evaluateIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -589,6 +719,38 @@ func evaluateIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+// evaluates the value and then the bounds in order, with NULL for the
+// bounds that are left out
+func evaluateSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Evaluate(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := []object.Object{}
+	for _, bound := range []ast.Expression{node.Start, node.End, node.Step} {
+		if bound == nil {
+			bounds = append(bounds, object.NULL)
+			continue
+		}
+
+		value := Evaluate(bound, env)
+		if isError(value) {
+			return value
+		}
+		bounds = append(bounds, value)
+	}
+
+	slice, err := object.Slice(left, bounds[0], bounds[1], bounds[2])
+	if err != nil {
+		return newError("%s", err.Error())
+	}
+	return slice
+}
+
 func evaluateArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -604,13 +766,14 @@ func evaluateArrayIndexExpression(array, index object.Object) object.Object {
 func evaluateStringIndexExpression(str, index object.Object) object.Object {
 	strObj := str.(*object.String)
 	idx := index.(*object.Integer).Value
-	maximum := int64(len(strObj.Value) - 1)
+	runes := strObj.Runes()
+	maximum := int64(len(runes) - 1)
 
 	if idx < 0 || idx > maximum {
 		return object.NULL
 	}
 
-	return &object.String{Value: string(strObj.Value[idx])}
+	return &object.String{Value: string(runes[idx])}
 }
 
 func evaluateHashIndexExpression(hash, index object.Object) object.Object {
@@ -621,7 +784,7 @@ func evaluateHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 	
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return object.NULL
 	}
@@ -633,9 +796,9 @@ func evaluateHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Evaluate(keyNode, env)
 		if isError(key) {
 			return key
@@ -646,14 +809,13 @@ func evaluateHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Evaluate(valueNode, env)
+		value := Evaluate(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
@@ -63,6 +63,21 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != object.NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -71,6 +86,67 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	return true
 }
 
+// checks the object against the expected value of a test table. Strings
+// are String objects, a []int or []string is an array of them, and an
+// Error is an error with the same message
+func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, actual, int64(expected))
+	case bool:
+		testBooleanObject(t, actual, expected)
+	case string:
+		testStringObject(t, actual, expected)
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(expected), len(array.Elements))
+			return
+		}
+
+		for i, element := range expected {
+			testIntegerObject(t, array.Elements[i], int64(element))
+		}
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(expected), len(array.Elements))
+			return
+		}
+
+		for i, element := range expected {
+			testStringObject(t, array.Elements[i], element)
+		}
+	case *object.Error:
+		errObj, ok := actual.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if errObj.Message != expected.Message {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				expected.Message, errObj.Message)
+		}
+	default:
+		t.Errorf("unsupported expected value %T", expected)
+	}
+}
+
 func TestFloatLiteral(t *testing.T) {
 	input := `0.3333`
 
@@ -179,6 +255,17 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"true or false", true},
 		{"false or true", true},
 		{"true or true", true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1] == [\"1\"]", false},
+		{"{\"a\": [1], \"b\": 2} == {\"b\": 2, \"a\": [1]}", true},
+		{"{\"a\": 1} == {\"a\": 2}", false},
+		{"{\"a\": 1} == {\"b\": 1}", false},
+		{"[] == {}", false},
+		{"1 == \"1\"", false},
 	}
 
 	for _, test := range tests {
@@ -495,6 +582,96 @@ func TestBuiltinFunctions(t *testing.T) {
 	testIntegerObject(t, result.Elements[2], 6)
 }
 
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`split("a,b,,c", ",")`, []string{"a", "b", "", "c"}},
+		{`split("héj", "")`, []string{"h", "é", "j"}},
+		{
+			`split(1, ",")`,
+			&object.Error{Message: "argument 1 to `split` must be STRING, got INTEGER"},
+		},
+		{`join(["a", 1, true], "-")`, "a-1-true"},
+		{`join([], ",")`, ""},
+		{
+			`let a = []; let i = 0; while (i < 300) { a = push(a, i); i = i + 1; }; join(a, repeat("-", 1000000))`,
+			&object.Error{Message: "result of `join` is too large, more than 268435456 bytes"},
+		},
+		{
+			`join("ab", ",")`,
+			&object.Error{Message: "argument 1 to `join` must be ARRAY, got STRING"},
+		},
+		{`trim("  a b \n")`, "a b"},
+		{`trimLeft("  a ") + "."`, "a ."},
+		{`trimRight("  a ") + "."`, "  a."},
+		{
+			`trim()`,
+			&object.Error{Message: "wrong number of arguments to `trim`. got=0, want=1"},
+		},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("abc", "", "-")`, "-a-b-c-"},
+		{
+			`let s = repeat("a", 4000); replace(s, "", repeat("b", 100000))`,
+			&object.Error{Message: "result of `replace` is too large, more than 268435456 bytes"},
+		},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "xyz")`, false},
+		{`startsWith("hello", "he")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ABC")`, "abc"},
+		{`repeat("ab", 3)`, "ababab"},
+		{
+			`repeat("ab", -1)`,
+			&object.Error{Message: "argument 2 to `repeat` must not be negative, got -1"},
+		},
+		{
+			`repeat("ab", 9223372036854775807)`,
+			&object.Error{Message: "result of `repeat` is too large, more than 268435456 bytes"},
+		},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`substr("héllo", 1)`, "éllo"},
+		{`substr("héllo", 1, 2)`, "él"},
+		{`substr("héllo", 3, 10)`, "lo"},
+		{
+			`substr("héllo", 6)`,
+			&object.Error{Message: "start of `substr` out of range, got 6 for length 5"},
+		},
+		{
+			`substr("a")`,
+			&object.Error{Message: "wrong number of arguments to `substr`. got=1, want 2 or 3"},
+		},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`lines("a\r\nb\n\nc\n")`, []string{"a", "b", "", "c"}},
+		{`lines("")`, []string{}},
+		{`padLeft("7", 3, "0")`, "007"},
+		{`padRight("ab", 5, "-=")`, "ab-=-"},
+		{`padRight("a", 7, "é-")`, "aé-é-é-"},
+		{`padLeft("abc", 2)`, "abc"},
+		{`padLeft("a", 3) + "."`, "  a."},
+		{
+			`padLeft("a", 3, "")`,
+			&object.Error{Message: "padding of `padLeft` must not be empty"},
+		},
+		{
+			`padLeft("a", 1000000000000)`,
+			&object.Error{Message: "result of `padLeft` is too large, more than 268435456 bytes"},
+		},
+		{
+			`padLeft("a", 9223372036854775807, "xy")`,
+			&object.Error{Message: "result of `padLeft` is too large, more than 268435456 bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.expected, testEval(tt.input))
+	}
+}
+
 func TestArrayIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -570,26 +747,30 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		object.TRUE.HashKey():                      5,
-		object.FALSE.HashKey():                     6,
+	// in the order of the literal
+	expected := []struct {
+		key   object.Object
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{object.TRUE, 5},
+		{object.FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	pairs := result.Pairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(pairs))
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+	for i, tt := range expected {
+		if !object.Equal(pairs[i].Key, tt.key) {
+			t.Errorf("pair %d has wrong key. want=%s, got=%s", i, tt.key.Inspect(), pairs[i].Key.Inspect())
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, pairs[i].Value, tt.value)
 	}
 }
 
@@ -639,6 +820,49 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[1, 2, 3, 4, 5][1:3]`, []int{2, 3}},
+		{`[1, 2, 3, 4, 5][:2]`, []int{1, 2}},
+		{`[1, 2, 3, 4, 5][3:]`, []int{4, 5}},
+		{`[1, 2, 3, 4, 5][:]`, []int{1, 2, 3, 4, 5}},
+		{`[1, 2, 3, 4, 5][-2:]`, []int{4, 5}},
+		{`[1, 2, 3, 4, 5][:-3]`, []int{1, 2}},
+		{`[1, 2, 3, 4, 5][::2]`, []int{1, 3, 5}},
+		{`[1, 2, 3, 4, 5][::-1]`, []int{5, 4, 3, 2, 1}},
+		{`[1, 2, 3, 4, 5][3:0:-1]`, []int{4, 3, 2}},
+		{`[1, 2, 3, 4, 5][-10:10]`, []int{1, 2, 3, 4, 5}},
+		{`[1, 2, 3, 4, 5][4:1]`, []int{}},
+		{`let a = [1, 2, 3]; let i = 1; a[i:i + 1]`, []int{2}},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[::-1]`, "olléh"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"abc"[5:]`, ""},
+		{`[1, 2, 3][2::9223372036854775807]`, []int{3}},
+		{`[1, 2, 3][::-9223372036854775807]`, []int{3}},
+		{`"abc"[-9223372036854775807:9223372036854775807]`, "abc"},
+		{
+			`[1, 2, 3][::0]`,
+			&object.Error{Message: "slice step cannot be zero"},
+		},
+		{
+			`[1, 2, 3]["a":]`,
+			&object.Error{Message: "slice start must be INTEGER, got STRING"},
+		},
+		{
+			`{1: 2}[0:1]`,
+			&object.Error{Message: "slice operator not supported: HASH"},
+		},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.expected, testEval(tt.input))
+	}
+}
+
 func TestWhileExpression(t *testing.T) {
 	var input string = `
 	let x = 1;
@@ -661,13 +885,13 @@ func TestSwitchExpression(t *testing.T) {
 	let sum = 10
 	switch (x) {
 		case "goodbye" {
-			let sum = 20
+			sum = 20
 		}
 		case "hello" {
-			let sum = 30
+			sum = 30
 		}
 		default {
-			let sum = 40
+			sum = 40
 		}
 	}
 	sum
@@ -675,3 +899,181 @@ func TestSwitchExpression(t *testing.T) {
 	evaluated := testEval(input)
 	testIntegerObject(t, evaluated, 30)
 }
+
+func TestRecursionDepth(t *testing.T) {
+	input := `
+	let sum = func(x) {
+		if (x == 0) {
+			return 0;
+		} else {
+			x + sum(x - 1);
+		}
+	};
+	sum(1000);
+	`
+	testIntegerObject(t, testEval(input), 500500)
+
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"let f = func(x) { 1 + f(x + 1) }; f(0);",
+			"maximum recursion depth exceeded: f (x1024) <- <main>",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != test.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				test.expectedMessage, errObj.Message)
+		}
+	}
+
+	if len(callStack) != 0 {
+		t.Errorf("call stack not unwound. got=%d", len(callStack))
+	}
+
+	MaxCallDepth = 4
+	defer func() { MaxCallDepth = 1024 }()
+
+	evaluated := testEval("let f = func(x) { 1 + func() { 1 + f(x) }() }; f(0);")
+	expected := "maximum recursion depth exceeded: " +
+		"<anonymous> <- f <- <anonymous> <- f <- <main>"
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`
+			let count = func(x, acc) {
+				if (x == 0) {
+					return acc;
+				}
+				count(x - 1, acc + 1);
+			};
+			count(100000, 0);
+			`,
+			100000,
+		},
+		{
+			`
+			let f = func(x) {
+				switch (x) {
+					case 0 { len("done") }
+					default { return f(x - 1); }
+				}
+			};
+			f(5000);
+			`,
+			4,
+		},
+		{
+			`
+			let wrapper = func(a) {
+				let inner = func(b, c) { a + b + c };
+				inner(2, 3);
+			};
+			wrapper(1) + wrapper(10);
+			`,
+			21,
+		},
+	}
+
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+
+	evaluated := testEval("let f = func(a) { a }; let g = func() { f() }; g();")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := "wrong number of arguments: want=1, got=0"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+	}
+}
+
+// exit unwinds the whole program wherever it is called
+func TestExit(t *testing.T) {
+	for _, input := range []string{
+		"exit(3); 5",
+		"let f = func() { while (true) { exit(3); } }; f(); 5",
+		"let f = func(x) { if (x == 0) { exit(3) }; f(x - 1) }; [f(10), 5]",
+		"switch (1) { case 1 { let a = exit(3); } }; 5",
+	} {
+		evaluated := testEval(input)
+		exit, ok := evaluated.(*object.Exit)
+		if !ok {
+			t.Errorf("no exit returned for %q. got=%T(%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if exit.Status != 3 {
+			t.Errorf("wrong exit status for %q. want=3, got=%d", input, exit.Status)
+		}
+	}
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; if (true) { let x = x + 10; x };", 11},
+		{"let x = 1; if (true) { let x = x + 10; }; x;", 1},
+		{"let x = 1; if (true) { x = x + 10; }; x;", 11},
+		{
+			`
+			let fs = [];
+			let i = 0;
+			while (i < 3) {
+				let j = i;
+				fs = push(fs, func() { j });
+				i = i + 1;
+			};
+			fs[0]() + fs[1]() * 10 + fs[2]() * 100;
+			`,
+			210,
+		},
+	}
+
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+
+	evaluated := testEval("if (true) { let a = 1; }; a;")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := "identifier not found: a"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+	}
+}
@@ -5,7 +5,7 @@ while (i < 10) {
     i = i + 1;
 }*/
 
-let i = 4; 
+let i = 4;
 
 switch (i) {
     case 1 {
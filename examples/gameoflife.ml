@@ -17,7 +17,7 @@ let populate = func(rows, cols) {
         i = i + 1;
     }
     return arr;
-}
+};
 
 let solve = func(arr) {
     let rows = len(arr);
@@ -33,37 +33,71 @@ let solve = func(arr) {
             let down = i + 1;
             let left = j - 1;
             let right = j + 1;
-            if (up < 0) { up = rows - 1 };
-            if (down > rows - 1) { down = 0 };
-            if (left < 0) {left = cols - 1};
-            if (right > cols - 1) { right = 0};
+            if (up < 0) {
+                up = rows - 1;
+            }
+            if (down > rows - 1) {
+                down = 0;
+            }
+            if (left < 0) {
+                left = cols - 1;
+            }
+            if (right > cols - 1) {
+                right = 0;
+            }
+
+            if (arr[up][left] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[up][j] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[up][right] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[i][left] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[i][right] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[down][left] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[down][j] == "#") {
+                neighbours = neighbours + 1;
+            }
+            if (arr[down][right] == "#") {
+                neighbours = neighbours + 1;
+            }
 
-            if (arr[up][left] == "#") { neighbours = neighbours + 1 };
-            if (arr[up][j] == "#") { neighbours = neighbours + 1 };
-            if (arr[up][right] == "#") { neighbours = neighbours + 1 };
-            if (arr[i][left] == "#") { neighbours = neighbours + 1 };
-            if (arr[i][right] == "#") { neighbours = neighbours + 1 };
-            if (arr[down][left] == "#") { neighbours = neighbours + 1 };
-            if (arr[down][j] == "#") { neighbours = neighbours + 1 };
-            if (arr[down][right] == "#") { neighbours = neighbours + 1 };
-            
             let dead = true;
-            if (arr[i][j] == "#") { dead = false; };
+            if (arr[i][j] == "#") {
+                dead = false;
+            }
 
             if (dead) {
-                if (neighbours == 3) { dead = false; };
+                if (neighbours == 3) {
+                    dead = false;
+                }
             } else {
                 switch (neighbours) {
-                    case 2 { dead = false; }
-                    case 3 { dead = false; }
-                    default { dead = true; }
+                    case 2 {
+                        dead = false;
+                    }
+                    case 3 {
+                        dead = false;
+                    }
+                    default {
+                        dead = true;
+                    }
                 }
             }
 
             if (dead) {
-                assign(out[i], j, " ");
+                assign(out, i, assign(out[i], j, " "));
             } else {
-                assign(out[i], j, "#");
+                assign(out, i, assign(out[i], j, "#"));
             }
             j = j + 1;
         }
@@ -71,7 +105,7 @@ let solve = func(arr) {
         i = i + 1;
     }
     return out;
-}
+};
 
 let printArr = func(arr) {
     let i = 0;
@@ -79,7 +113,7 @@ let printArr = func(arr) {
         puts(arr[i]);
         i = i + 1;
     }
-}
+};
 
 let running = true;
 let input = open("/dev/stdin");
@@ -88,23 +122,23 @@ let clear = command("clear")["stdout"];
 while (running) {
     puts("enter x dimension");
     let str = read(input);
-    pop(str);
+    str = pop(str);
     let cols = int(str);
     puts("enter y dimension");
     str = read(input);
-    pop(str);
+    str = pop(str);
     let rows = int(str);
-    
+
     puts(clear);
     let arr = populate(rows, cols);
     printArr(arr);
-    
+
     let solving = true;
     while (solving) {
         puts("press enter to iterate and anything else to stop");
         let stop = read(input);
         puts(clear);
-        if (stop != "\n") { 
+        if (stop != "\n") {
             solving = false;
         } else {
             arr = solve(arr);
@@ -114,7 +148,7 @@ while (running) {
 
     puts("quit program, y or n?");
     let quit = read(input);
-    pop(quit);
+    quit = pop(quit);
     if (quit == "y") {
         running = false;
     }
@@ -0,0 +1,539 @@
+// canonical formatting of mylang source, used by "mylang fmt"
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"mylang/ast"
+	"mylang/lexer"
+	"mylang/parser"
+	"mylang/token"
+)
+
+// one level of indentation
+const INDENT = "    "
+
+// formats the source, keeping its comments and single blank lines
+// between statements. Returns the syntax errors instead when the source
+// does not parse, since a broken program can not be printed faithfully
+func Format(source string) (string, []*parser.ParseError) {
+	p := parser.New(lexer.NewWithComments(source))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		return "", p.ParseErrors()
+	}
+
+	pr := &printer{
+		comments: program.Comments,
+		lines: strings.Split(source, "\n"),
+		blockStart: true,
+	}
+	pr.statements(program.Statements, token.Token{Line: math.MaxInt})
+
+	return pr.out.String(), nil
+}
+
+type printer struct {
+	out strings.Builder
+	indent int
+
+	// comments of the program in source order, next is the first one
+	// that has not been printed
+	comments []*ast.Comment
+	next int
+
+	// the lines of the source, used to find blank lines
+	lines []string
+	// set until the first line of a block has been written, so blocks
+	// never start with a blank line
+	blockStart bool
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+func (p *printer) writeIndent() {
+	p.write(strings.Repeat(INDENT, p.indent))
+}
+
+// writes a blank line when the source had one right above the given
+// line, leaving out blank lines at the start of blocks
+func (p *printer) keepBlankLine(line int) {
+	if !p.blockStart && line >= 2 && line - 2 < len(p.lines) &&
+		strings.TrimSpace(p.lines[line - 2]) == "" {
+		p.write("\n")
+	}
+	p.blockStart = false
+}
+
+// prints every comment that comes before the position. Trailing comments
+// go at the end of the last line written, others on lines of their own
+func (p *printer) commentsBefore(position token.Token) {
+	for p.next < len(p.comments) && before(p.comments[p.next].Token, position) {
+		comment := p.comments[p.next]
+		p.next++
+
+		written := p.out.String()
+		if comment.Trailing && strings.HasSuffix(written, "\n") {
+			p.out.Reset()
+			p.write(strings.TrimSuffix(written, "\n"))
+			p.write(" " + comment.Token.Literal + "\n")
+			continue
+		}
+
+		p.keepBlankLine(comment.Token.Line)
+		p.writeIndent()
+		p.write(comment.Token.Literal + "\n")
+	}
+}
+
+func before(a token.Token, b token.Token) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// prints the statements one per line, followed by the comments that come
+// before the end of the block
+func (p *printer) statements(statements []ast.Statement, end token.Token) {
+	for i, statement := range statements {
+		start := startToken(statement)
+
+		p.commentsBefore(start)
+		p.keepBlankLine(start.Line)
+		p.writeIndent()
+		p.statement(statement)
+
+		if i + 1 < len(statements) && needsSemicolon(statement, statements[i + 1]) {
+			p.write(";")
+		}
+		p.write("\n")
+	}
+
+	p.commentsBefore(end)
+}
+
+func (p *printer) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		p.write("let " + statement.Name.Value + " = ")
+		p.expression(statement.Value)
+		p.write(";")
+
+	case *ast.AssignmentStatement:
+		p.write(statement.Name.Value + " = ")
+		p.expression(statement.Value)
+		p.write(";")
+
+	case *ast.ReturnStatement:
+		p.write("return")
+		if statement.ReturnValue != nil {
+			p.write(" ")
+			p.expression(statement.ReturnValue)
+		}
+		p.write(";")
+
+	case *ast.ExpressionStatement:
+		p.expression(statement.Expression)
+		if !endsWithBlock(statement) {
+			p.write(";")
+		}
+
+	case *ast.BlockStatement:
+		p.block(statement)
+	}
+}
+
+// expressions that end with a block read as statements without a
+// semicolon
+func endsWithBlock(statement ast.Statement) bool {
+	expression, ok := statement.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	switch expression.Expression.(type) {
+	case *ast.IfExpression, *ast.WhileExpression, *ast.SwitchExpression:
+		return true
+	}
+	return false
+}
+
+// reports whether a statement printed without a semicolon would run into
+// the next one, like an if followed by a statement starting with "(",
+// which would parse as a call of the if
+func needsSemicolon(statement ast.Statement, next ast.Statement) bool {
+	if !endsWithBlock(statement) {
+		return false
+	}
+
+	switch startToken(next).Type {
+	case token.OPAREN, token.OBRACKET, token.MINUS:
+		return true
+	}
+	return false
+}
+
+// prints a block with its statements indented, or {} if it is empty
+func (p *printer) block(block *ast.BlockStatement) {
+	hasComments := p.next < len(p.comments) && before(p.comments[p.next].Token, block.Close)
+	if len(block.Statements) == 0 && !hasComments {
+		p.write("{}")
+		return
+	}
+
+	p.write("{\n")
+	p.indent++
+	p.blockStart = true
+
+	p.statements(block.Statements, block.Close)
+
+	p.indent--
+	p.blockStart = false
+	p.writeIndent()
+	p.write("}")
+}
+
+func (p *printer) expression(expression ast.Expression) {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		p.write(expression.Value)
+
+	case *ast.IntegerLiteral:
+		p.write(expression.Token.Literal)
+
+	case *ast.FloatLiteral:
+		p.write(expression.Token.Literal)
+
+	case *ast.BooleanLiteral:
+		p.write(expression.Token.Literal)
+
+	case *ast.StringLiteral:
+		if literal, ok := p.rawLiteral(expression.Token); ok {
+			p.write(literal)
+		} else if text, ok := p.quotedSource(expression.Token); ok {
+			p.write("\"" + text + "\"")
+		} else {
+			p.write(quote(expression.Value))
+		}
+
+	case *ast.InterpolatedString:
+		p.write("\"")
+		for _, part := range expression.Parts {
+			if literal, ok := part.(*ast.StringLiteral); ok {
+				if text, ok := p.quotedSource(literal.Token); ok {
+					p.write(text)
+				} else {
+					p.write(escape(literal.Value))
+				}
+			} else {
+				p.write("${")
+				p.expression(part)
+				p.write("}")
+			}
+		}
+		p.write("\"")
+
+	case *ast.PrefixExpression:
+		p.write(expression.Operator)
+		p.operand(expression.Right, parser.PREFIX, false)
+
+	case *ast.InfixExpression:
+		precedence := parser.Precedence(expression.Token.Type)
+		p.operand(expression.Left, precedence, false)
+		p.write(" " + expression.Operator + " ")
+		p.operand(expression.Right, precedence, true)
+
+	case *ast.CallExpression:
+		p.operand(expression.Function, parser.CALL, false)
+		p.write("(")
+		p.list(expression.Arguments)
+		p.write(")")
+
+	case *ast.IndexExpression:
+		p.operand(expression.Left, parser.INDEX, false)
+		p.write("[")
+		p.expression(expression.Index)
+		p.write("]")
+
+	case *ast.SliceExpression:
+		p.operand(expression.Left, parser.INDEX, false)
+		p.write("[")
+		if expression.Start != nil {
+			p.expression(expression.Start)
+		}
+		p.write(":")
+		if expression.End != nil {
+			p.expression(expression.End)
+		}
+		if expression.Step != nil {
+			p.write(":")
+			p.expression(expression.Step)
+		}
+		p.write("]")
+
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.list(expression.Elements)
+		p.write("]")
+
+	case *ast.HashLiteral:
+		p.write("{")
+		for i, key := range expression.Keys {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(key)
+			p.write(": ")
+			p.expression(expression.Pairs[key])
+		}
+		p.write("}")
+
+	case *ast.FunctionLiteral:
+		p.write("func(")
+		for i, parameter := range expression.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(parameter.Value)
+		}
+		p.write(") ")
+		p.block(expression.Body)
+
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(expression.Condition)
+		p.write(") ")
+		p.block(expression.Consequence)
+		if expression.Alternative != nil {
+			p.write(" else ")
+			p.block(expression.Alternative)
+		}
+
+	case *ast.WhileExpression:
+		p.write("while (")
+		p.expression(expression.Condition)
+		p.write(") ")
+		p.block(expression.Body)
+
+	case *ast.SwitchExpression:
+		p.write("switch (")
+		p.expression(expression.Value)
+		p.write(") {\n")
+		p.indent++
+
+		for _, c := range expression.Cases {
+			p.commentsBefore(c.Token)
+			p.writeIndent()
+			if c.Default {
+				p.write("default ")
+			} else {
+				p.write("case ")
+				p.expression(c.Value)
+				p.write(" ")
+			}
+			p.block(c.Body)
+			p.write("\n")
+		}
+
+		p.indent--
+		p.writeIndent()
+		p.write("}")
+	}
+}
+
+// prints an operand of an operator with the given precedence, adding
+// parentheses when the operand binds more loosely than the operator.
+// Operators are left associative so a right operand of the same
+// precedence needs them too
+func (p *printer) operand(operand ast.Expression, precedence int, right bool) {
+	var inner int = parser.INDEX
+	switch operand := operand.(type) {
+	case *ast.InfixExpression:
+		inner = parser.Precedence(operand.Token.Type)
+	case *ast.PrefixExpression:
+		inner = parser.PREFIX
+	}
+
+	if inner < precedence || (right && inner == precedence) {
+		p.write("(")
+		p.expression(operand)
+		p.write(")")
+	} else {
+		p.expression(operand)
+	}
+}
+
+func (p *printer) list(expressions []ast.Expression) {
+	for i, expression := range expressions {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(expression)
+	}
+}
+
+// writes the string as a literal, escaping what the lexer unescapes
+func quote(value string) string {
+	return "\"" + escape(value) + "\""
+}
+
+// escapes the text of a string so it reads back the same, including a
+// ${ that would otherwise start an interpolation. Characters that can not
+// be seen, like a zero width space, are escaped too. Only used when the
+// string is not in the source, which keeps the escapes as written
+func escape(value string) string {
+	var out strings.Builder
+	runes := []rune(value)
+
+	for i, char := range runes {
+		switch {
+		case char == '$' && i + 1 < len(runes) && runes[i + 1] == '{':
+			out.WriteString("\\$")
+		case char == '\\':
+			out.WriteString("\\\\")
+		case char == '"':
+			out.WriteString("\\\"")
+		case char == '\n':
+			out.WriteString("\\n")
+		case char == '\r':
+			out.WriteString("\\r")
+		case char == '\t':
+			out.WriteString("\\t")
+		case char == 0:
+			out.WriteString("\\0")
+		case char < ' ' || char == 0x7f:
+			out.WriteString(fmt.Sprintf("\\x%02x", char))
+		case !unicode.IsPrint(char) && char <= 0xffff:
+			out.WriteString(fmt.Sprintf("\\u%04x", char))
+		default:
+			out.WriteRune(char)
+		}
+	}
+
+	return out.String()
+}
+
+// returns the source from the start of the token to the end
+func (p *printer) sourceAt(tok token.Token) ([]rune, bool) {
+	if tok.Line < 1 || tok.Line > len(p.lines) {
+		return nil, false
+	}
+
+	rest := []rune(strings.Join(p.lines[tok.Line - 1:], "\n"))
+	if tok.Column < 1 || tok.Column > len(rest) {
+		return nil, false
+	}
+	return rest[tok.Column - 1:], true
+}
+
+// returns the text of a quoted string as it is written in the source,
+// without the quotation marks, so the escapes the author chose are kept.
+// For the parts of an interpolated string the token starts on the opening
+// quotation mark or on the } that ends an interpolation, and the text
+// ends at the next ${
+func (p *printer) quotedSource(tok token.Token) (string, bool) {
+	rest, ok := p.sourceAt(tok)
+	if !ok || (rest[0] != '"' && rest[0] != '}') {
+		return "", false
+	}
+
+	for i := 1; i < len(rest); i++ {
+		switch {
+		case rest[i] == '\\':
+			i++
+		case rest[i] == '"':
+			return string(rest[1:i]), true
+		case rest[i] == '$' && i + 1 < len(rest) && rest[i + 1] == '{':
+			return string(rest[1:i]), true
+		}
+	}
+
+	return "", false
+}
+
+// returns raw and multi-line strings as they are written in the source,
+// since quoting their value would lose their layout
+func (p *printer) rawLiteral(tok token.Token) (string, bool) {
+	rest, ok := p.sourceAt(tok)
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case rest[0] == '`':
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '`' {
+				return string(rest[:i + 1]), true
+			}
+		}
+
+	case strings.HasPrefix(string(rest), `"""`):
+		for i := 3; i + 2 < len(rest); i++ {
+			if rest[i] == '\\' {
+				i++
+				continue
+			}
+			if rest[i] == '"' && rest[i + 1] == '"' && rest[i + 2] == '"' {
+				return string(rest[:i + 3]), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// returns the first token of a statement or expression in the source
+func startToken(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.AssignmentStatement:
+		return node.Name.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.InfixExpression:
+		return startToken(node.Left)
+	case *ast.CallExpression:
+		return startToken(node.Function)
+	case *ast.IndexExpression:
+		return startToken(node.Left)
+	case *ast.SliceExpression:
+		return startToken(node.Left)
+	case *ast.Identifier:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.FloatLiteral:
+		return node.Token
+	case *ast.BooleanLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.InterpolatedString:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.WhileExpression:
+		return node.Token
+	case *ast.SwitchExpression:
+		return node.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,174 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"mylang/lexer"
+	"mylang/parser"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x=1+2*3;let y = (1 + 2) * 3;  let z = 1 - (2 - 3)",
+			"let x = 1 + 2 * 3;\nlet y = (1 + 2) * 3;\nlet z = 1 - (2 - 3);\n",
+		},
+		{
+			"let s = \"a\\\"b\\n\";let h = {\"b\": 1, \"a\": [1,2]}; -(a + b); !f(x)[0]",
+			"let s = \"a\\\"b\\n\";\nlet h = {\"b\": 1, \"a\": [1, 2]};\n-(a + b);\n!f(x)[0];\n",
+		},
+		{
+			"let f = func(a,b){ if (a > b) { return a } else { b } };",
+			"let f = func(a, b) {\n" +
+				"    if (a > b) {\n" +
+				"        return a;\n" +
+				"    } else {\n" +
+				"        b;\n" +
+				"    }\n" +
+				"};\n",
+		},
+		{
+			"while (i < 3) { i = i + 1; };\nswitch (i) { case 3 { puts(i) } default {} }",
+			"while (i < 3) {\n" +
+				"    i = i + 1;\n" +
+				"}\n" +
+				"switch (i) {\n" +
+				"    case 3 {\n" +
+				"        puts(i);\n" +
+				"    }\n" +
+				"    default {}\n" +
+				"}\n",
+		},
+		{
+			"if (a) { 1 }; (b)();",
+			"if (a) {\n    1;\n};\nb();\n",
+		},
+		{
+			"puts(\"a ${x+1} \\${b} ${ {\"k\": \"${y}\"}[\"k\"] }\")",
+			"puts(\"a ${x + 1} \\${b} ${{\"k\": \"${y}\"}[\"k\"]}\");\n",
+		},
+		{
+			"let s = \"a\\u200bb \\u00e9 \\x41\";let t = \"\\t${ x }\\u00e9${y}\\\"\"",
+			"let s = \"a\\u200bb \\u00e9 \\x41\";\nlet t = \"\\t${x}\\u00e9${y}\\\"\";\n",
+		},
+		{
+			"a[ 1 : ]; a[:-1]; a[::-1]; a[i+1:j:2][0]; (a+b)[1:2]",
+			"a[1:];\na[:-1];\na[::-1];\na[i + 1:j:2][0];\n(a + b)[1:2];\n",
+		},
+		{
+			"let r = `a\\n\nb`;let m = \"\"\"\n  x \\\"\"\"\n  \"\"\";let e = \"\\x41\\0\"",
+			"let r = `a\\n\nb`;\nlet m = \"\"\"\n  x \\\"\"\"\n  \"\"\";\nlet e = \"\\x41\\0\";\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, errors := Format(tt.input)
+		if len(errors) != 0 {
+			t.Fatalf("unexpected parse errors for %q: %v", tt.input, errors)
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("wrong formatting.\nwant=%q\ngot= %q", tt.expected, formatted)
+		}
+	}
+}
+
+// escape is used for strings that are not in the source and has to keep
+// characters that can not be seen visible
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"a\u200bb", `a\u200bb`},
+		{"\u00a0é世", `\u00a0é世`},
+		{"\"\\\n\x01${", `\"\\\n\x01\${`},
+	}
+
+	for _, tt := range tests {
+		if escaped := escape(tt.value); escaped != tt.expected {
+			t.Errorf("wrong escape for %q. want=%q, got=%q", tt.value, tt.expected, escaped)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `// counts to three
+let i = 0; // start
+
+
+/* the loop */
+while (i < 3) {
+  // step
+  i = i + 1;   // one more
+  // done
+}
+let a = [1, // one
+  2];
+// the end`
+
+	expected := `// counts to three
+let i = 0; // start
+
+/* the loop */
+while (i < 3) {
+    // step
+    i = i + 1; // one more
+    // done
+}
+let a = [1, 2]; // one
+// the end
+`
+
+	formatted, errors := Format(input)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected parse errors: %v", errors)
+	}
+
+	if formatted != expected {
+		t.Errorf("wrong formatting.\nwant=%q\ngot= %q", expected, formatted)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	_, errors := Format("let x = ;")
+	if len(errors) != 1 {
+		t.Errorf("expected one parse error. got=%d", len(errors))
+	}
+}
+
+// formatting the examples must not change what they mean, and formatting
+// them again must not change them any further
+func TestFormatExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.ml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("could not read %s: %s", file, err)
+		}
+
+		formatted, errors := Format(string(source))
+		if len(errors) != 0 {
+			t.Fatalf("unexpected parse errors in %s: %v", file, errors)
+		}
+
+		again, _ := Format(formatted)
+		if again != formatted {
+			t.Errorf("formatting %s is not stable", file)
+		}
+
+		original := parser.New(lexer.New(string(source))).ParseProgram()
+		result := parser.New(lexer.New(formatted)).ParseProgram()
+		if original.String() != result.String() {
+			t.Errorf("formatting changed the meaning of %s", file)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"fmt"
+	"strings"
 	"mylang/token"
 	"unicode"
 )
@@ -10,49 +12,97 @@ type Lexer struct {
 	position int
 	readPosition int
 	char rune
+	// position of the current character
+	line int
+	column int
+	// set when the input ends inside a string or block comment
+	unterminated bool
+	// whether comments are returned as COMMENT tokens or skipped
+	keepComments bool
+	// problems found in the input, like a string that is not terminated.
+	// The lexer still returns a token for the broken part
+	errors []*Error
+	// the ${ } of interpolated strings the lexer is inside of, innermost
+	// last
+	templates []*template
+}
+
+// an interpolation being read. depth counts the braces opened inside it,
+// so the } that ends it can be told apart. line and column are where its
+// string starts
+type template struct {
+	depth int
+	line int
+	column int
+}
+
+// a problem with the input found by the lexer, at the position of the
+// character it was found at
+type Error struct {
+	Line int
+	Column int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s, at line %d, column %d", e.Message, e.Line, e.Column)
+}
+
+// the problems found in the input read so far
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+func (l *Lexer) addError(line int, column int, format string, a ...interface{}) {
+	l.errors = append(l.errors, &Error{Line: line, Column: column, Message: fmt.Sprintf(format, a...)})
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
-	l.readChar()
-	return l
+	return NewAtLine(input, 1)
 }
 
-// creates the next token in the lexer input
-func (l *Lexer) NextToken() (tok token.Token) {
-	l.skipWhitespace()
+// creates a lexer that numbers the lines of the input from the given
+// line, so that the repl can number each input on from the ones before it
+func NewAtLine(input string, line int) *Lexer {
+	l := &Lexer{input: []rune(input), line: line}
+	l.readChar()
 
-	// skip single line comment
-	if l.char == rune('/') && l.peekChar() == rune('/') {
+	// a "#!" line at the start lets scripts be run directly
+	if l.char == '#' && l.peekChar() == '!' {
 		for l.char != '\n' && l.char != rune(0) {
 			l.readChar()
 		}
-		l.skipWhitespace()
-
-		return l.NextToken()
 	}
 
-	// skipe block comment
-	if l.char == rune('/') && l.peekChar() == rune('*') {
-		var end bool = false
-
-		for !end {
-			if l.char == rune(0) {
-				end = true
-			}
+	return l
+}
 
-			if l.char == '*' && l.peekChar() == '/' {
-				end = true
+// creates a lexer that returns comments as COMMENT tokens instead of
+// skipping them, used by the formatter to keep them
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.keepComments = true
+	return l
+}
 
-				l.readChar()
-			}
+// creates the next token in the lexer input
+func (l *Lexer) NextToken() (tok token.Token) {
+	l.skipWhitespace()
 
-			l.readChar()
+	if l.char == rune('/') && (l.peekChar() == rune('/') || l.peekChar() == rune('*')) {
+		comment := l.readComment()
+		if l.keepComments {
+			return comment
 		}
-
-		l.skipWhitespace()
+		return l.NextToken()
 	}
 
+	line, column := l.line, l.column
+	defer func() {
+		tok.Line = line
+		tok.Column = column
+	}()
+
 	switch l.char {
 	case '=':
 		if l.peekChar() == '=' {
@@ -63,7 +113,14 @@ func (l *Lexer) NextToken() (tok token.Token) {
 		}
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		if l.peekChar() == '"' && l.peekCharAt(1) == '"' {
+			tok.Literal = l.readMultilineString()
+		} else {
+			tok = l.readString(l.line, l.column)
+		}
+	case '`':
+		tok.Type = token.STRING
+		tok.Literal = l.readRawString()
 	case ';':
 		tok = token.Token{Type: token.SCOLON, Literal: string(l.char)}
 	case ':':
@@ -73,8 +130,22 @@ func (l *Lexer) NextToken() (tok token.Token) {
 	case ')':
 		tok = token.Token{Type: token.CPAREN, Literal: string(l.char)}
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n - 1].depth++
+		}
 		tok = token.Token{Type: token.OBRACE, Literal: string(l.char)}
 	case '}':
+		n := len(l.templates)
+		if n > 0 && l.templates[n - 1].depth == 0 {
+			// the end of an interpolation, the string goes on
+			open := l.templates[n - 1]
+			l.templates = l.templates[:n - 1]
+			tok = l.readString(open.line, open.column)
+			break
+		}
+		if n > 0 {
+			l.templates[n - 1].depth--
+		}
 		tok = token.Token{Type: token.CBRACE, Literal: string(l.char)}
 	case '[':
 		tok = token.Token{Type: token.OBRACKET, Literal: string(l.char)}
@@ -137,6 +208,28 @@ func (l *Lexer) NextToken() (tok token.Token) {
 	return tok
 }
 
+// reports whether the input stops in the middle of something, with
+// parentheses, brackets, or braces left open or a string or block comment
+// that is not terminated. Used by the repl to keep reading lines until
+// a statement is complete
+func Incomplete(input string) bool {
+	l := New(input)
+	depth := 0
+
+	for {
+		tok := l.NextToken()
+
+		switch tok.Type {
+		case token.OPAREN, token.OBRACKET, token.OBRACE:
+			depth++
+		case token.CPAREN, token.CBRACKET, token.CBRACE:
+			depth--
+		case token.EOF:
+			return depth > 0 || l.unterminated || len(l.templates) > 0
+		}
+	}
+}
+
 func (l *Lexer) GetLine() int {
 	var line int = 0
 	var length int = len(l.input)
@@ -163,8 +256,15 @@ func isDigit(char rune) bool {
 // to the current character being read by the lexer and readPosition
 // refers to the next character being read. If readPositon is 
 // advanced past the end of the string, the character is set to
-// the eof character
+// the eof character. Also keeps track of the line and column of the
+// current character
 func (l *Lexer) readChar() {
+	if l.readPosition > 0 && l.position < len(l.input) && l.input[l.position] == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.char = 0
 	} else {
@@ -195,37 +295,36 @@ func (l *Lexer) readDigits() string {
 	return string(l.input[startPos:l.position])
 }
 
-// extracts a string from the lexer surrounded by quotations
-func (l *Lexer) readString() string {
-	var out string = ""
+// reads a single line comment up to the end of the line, or a block
+// comment up to its closing "*/", and returns it with its delimiters
+func (l *Lexer) readComment() token.Token {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+	var startPos int = l.position
+
+	if l.peekChar() == '/' {
+		for l.char != '\n' && l.char != rune(0) {
+			l.readChar()
+		}
+		tok.Literal = strings.TrimRight(string(l.input[startPos:l.position]), "\r")
+		return tok
+	}
 
+	l.readChar()
 	for {
 		l.readChar()
-		if l.char == '"' || l.char == 0 {
+		if l.char == rune(0) {
+			l.unterminated = true
 			break
 		}
-
-		if l.char == '\\' {
+		if l.char == '*' && l.peekChar() == '/' {
 			l.readChar()
-
-			switch l.char {
-			case 'n':
-				l.char = '\n'
-			case 'r':
-				l.char = '\r'
-			case 't':
-				l.char = '\t'
-			case '"':
-				l.char = '"'
-			case '\\':
-				l.char = '\\'
-			}
+			l.readChar()
+			break
 		}
-		
-		out = out + string(l.char)
 	}
 
-	return out
+	tok.Literal = string(l.input[startPos:l.position])
+	return tok
 }
 
 // reads through any identifier/keyword in the input string and
@@ -254,3 +353,11 @@ func (l *Lexer) peekChar() rune {
 		return l.input[l.readPosition]
 	}
 }
+
+// looks at the character the given distance after the next one
+func (l *Lexer) peekCharAt(distance int) rune {
+	if l.readPosition + distance >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition + distance]
+}
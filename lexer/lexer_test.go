@@ -203,3 +203,233 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	var input string = "let x = 5;\n// comment\n  \"a\\nb\" +\n\tfoo"
+
+	tests := []struct {
+		Type   token.TokenType
+		Line   int
+		Column int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SCOLON, 1, 10},
+		{token.STRING, 3, 3},
+		{token.PLUS, 3, 10},
+		{token.IDENT, 4, 2},
+		{token.EOF, 4, 5},
+	}
+
+	var l *Lexer = New(input)
+
+	for i, test := range tests {
+		var tok token.Token = l.NextToken()
+
+		if tok.Type != test.Type {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, test.Type, tok.Type)
+		}
+
+		if tok.Line != test.Line || tok.Column != test.Column {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, test.Line, test.Column, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let f = func(x) {", true},
+		{"let f = func(x) {\n\tx + 1\n}", false},
+		{"while (i < 10", true},
+		{"[1, 2,\n3", true},
+		{"{\"a\": [1, 2]}", false},
+		{"let s = \"hello", true},
+		{"let s = \"a { b\"", false},
+		{"let s = \"a \\\" b", true},
+		{"/* comment", true},
+		{"/* { */ 1", false},
+		{"// {", false},
+		{"1 }", false},
+		{"let s = `a {", true},
+		{"let s = `a { \\`", false},
+		{"let s = \"\"\"\n  a\n", true},
+		{"let s = \"\"\"\n  a\n  \"\"\"", false},
+		{"let s = \"a ${f(", true},
+		{"let s = \"a ${ {\"b\": 1}", true},
+		{"let s = \"a ${ {\"b\": 1}[\"b\"] } c\"", false},
+	}
+
+	for _, tt := range tests {
+		if Incomplete(tt.input) != tt.expected {
+			t.Errorf("Incomplete(%q) wrong. want=%t", tt.input, tt.expected)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb\t\"c\"\\"`, "a\nb\t\"c\"\\"},
+		{`"\x41\xe9\0"`, "A\u00e9\x00"},
+		{`"\u00e9\u4e16"`, "\u00e9\u4e16"},
+		{"`raw \\n \"quoted\"\nline`", "raw \\n \"quoted\"\nline"},
+		{"\"\"\"\n    one\n      two\n\n    three\n    \"\"\"", "one\n  two\n\nthree"},
+		{"\"\"\"a \\\"\"\" b\"\"\"", "a \"\"\" b"},
+		{"\"\"\"\n\tx\\ty\n\t\"\"\"", "x\ty"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING || tok.Literal != tt.expected {
+			t.Errorf("wrong string for %s. want=%q, got=%s %q", tt.input, tt.expected, tok.Type, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("unexpected errors for %s: %v", tt.input, l.Errors()[0])
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("string %s did not end at its closing quote. next=%+v", tt.input, next)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{`x = "abc`, "unterminated string", 1, 5},
+		{"x = `abc", "unterminated raw string", 1, 5},
+		{"\"\"\"\nabc\"", "unterminated multi-line string", 1, 1},
+		{`"a\qb"`, "invalid escape \\q in string", 1, 3},
+		{`"ab\x4"`, "\\x escape needs 2 hex digits", 1, 4},
+		{"\"\n \\u12\"", "\\u escape needs 4 hex digits", 2, 2},
+		{`"\ud800"`, "\\ud800 escape is not a valid code point", 1, 2},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("wrong number of errors for %s. want=1, got=%d", tt.input, len(errors))
+			continue
+		}
+		err := errors[0]
+		if err.Message != tt.message || err.Line != tt.line || err.Column != tt.column {
+			t.Errorf("wrong error for %s. want=%q at %d:%d, got=%q at %d:%d", tt.input,
+				tt.message, tt.line, tt.column, err.Message, err.Line, err.Column)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	var input string = `"a ${x + "${y}"} b ${ {1: 2}[1] } \${c}"`
+
+	tests := []struct {
+		Type    token.TokenType
+		Literal string
+	}{
+		{token.TEMPLATE, "a "},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.TEMPLATE, ""},
+		{token.IDENT, "y"},
+		{token.STRING, ""},
+		{token.TEMPLATE, " b "},
+		{token.OBRACE, "{"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.INT, "2"},
+		{token.CBRACE, "}"},
+		{token.OBRACKET, "["},
+		{token.INT, "1"},
+		{token.CBRACKET, "]"},
+		{token.STRING, " ${c}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tests[%d] wrong token. want=%s %q, got=%s %q",
+				i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+
+	l = New(`"a ${} b"`)
+	tok := l.NextToken()
+	if tok.Type != token.STRING || tok.Literal != "a  b" || len(l.Errors()) != 1 {
+		t.Errorf("empty interpolation not reported. got=%s %q, errors=%d",
+			tok.Type, tok.Literal, len(l.Errors()))
+	}
+}
+
+func TestShebang(t *testing.T) {
+	var l *Lexer = New("#!/usr/bin/env mylang\nlet x = 1;")
+
+	tok := l.NextToken()
+	if tok.Type != token.LET || tok.Line != 2 || tok.Column != 1 {
+		t.Errorf("shebang line not skipped. got=%+v", tok)
+	}
+}
+
+func TestComments(t *testing.T) {
+	var input string = "// one\nlet /* two */ x = 1; // three\n/* four"
+
+	tests := []struct {
+		Type    token.TokenType
+		Literal string
+		Line    int
+		Column  int
+	}{
+		{token.COMMENT, "// one", 1, 1},
+		{token.LET, "let", 2, 1},
+		{token.COMMENT, "/* two */", 2, 5},
+		{token.IDENT, "x", 2, 15},
+		{token.ASSIGN, "=", 2, 17},
+		{token.INT, "1", 2, 19},
+		{token.SCOLON, ";", 2, 20},
+		{token.COMMENT, "// three", 2, 22},
+		{token.COMMENT, "/* four", 3, 1},
+		{token.EOF, "", 3, 8},
+	}
+
+	var l *Lexer = NewWithComments(input)
+
+	for i, test := range tests {
+		var tok token.Token = l.NextToken()
+
+		if tok.Type != test.Type || tok.Literal != test.Literal {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, test.Type, test.Literal, tok.Type, tok.Literal)
+		}
+
+		if tok.Line != test.Line || tok.Column != test.Column {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, test.Line, test.Column, tok.Line, tok.Column)
+		}
+	}
+
+	// without NewWithComments they are skipped
+	l = New(input)
+	if tok := l.NextToken(); tok.Type != token.LET {
+		t.Errorf("comment not skipped. got=%s", tok.Type)
+	}
+}
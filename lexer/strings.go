@@ -0,0 +1,278 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"mylang/token"
+)
+
+// reads a string surrounded by quotation marks, or the rest of one after
+// an interpolation, with line and column giving where the string starts.
+// Returns a STRING that ends on the closing quotation mark, or a TEMPLATE
+// that ends on the { of a ${ when the string is interpolated. The value
+// has the escapes replaced
+//
+//	\n \r \t \" \\   newline, carriage return, tab, quote and backslash
+//	\0               the null character
+//	\$               a dollar sign, so "\${" is not an interpolation
+//	\xHH             the code point with the two hex digits, \xe9 is é
+//	\uXXXX           the code point with the four hex digits
+func (l *Lexer) readString(line int, column int) token.Token {
+	raw := []rune{}
+
+	for {
+		l.readChar()
+		if l.char == 0 {
+			l.unterminated = true
+			l.addError(line, column, "unterminated string")
+			break
+		}
+		if l.char == '"' {
+			break
+		}
+
+		if l.char == '$' && l.peekChar() == '{' {
+			if l.emptyInterpolation() {
+				continue
+			}
+
+			l.readChar()
+			l.templates = append(l.templates, &template{line: line, column: column})
+			return token.Token{Type: token.TEMPLATE, Literal: unescape(raw)}
+		}
+
+		if l.char == '\\' {
+			raw = append(raw, l.readEscape()...)
+			continue
+		}
+		raw = append(raw, l.char)
+	}
+
+	return token.Token{Type: token.STRING, Literal: unescape(raw)}
+}
+
+// reports an interpolation with nothing between ${ and }, and skips it
+// so the rest of the string is read as usual. The lexer is on the $
+func (l *Lexer) emptyInterpolation() bool {
+	var distance int = 1
+	for {
+		switch l.peekCharAt(distance) {
+		case ' ', '\t', '\n', '\r':
+			distance++
+			continue
+		case '}':
+			l.addError(l.line, l.column, "empty interpolation in string")
+			for i := 0; i <= distance; i++ {
+				l.readChar()
+			}
+			return true
+		}
+		return false
+	}
+}
+
+// reads a string surrounded by three quotation marks, ending on the last
+// of the closing ones. The string can span lines: a newline right after
+// the opening quotes is left out, and so is the last line when it only
+// holds the indentation of the closing quotes. The indentation the lines
+// have in common is removed, so the string can be indented along with
+// the code around it. Escapes work as in other strings, but ${ is not
+// an interpolation
+func (l *Lexer) readMultilineString() string {
+	line, column := l.line, l.column
+	l.readChar()
+	l.readChar()
+	raw := []rune{}
+
+	for {
+		l.readChar()
+		if l.char == 0 {
+			l.unterminated = true
+			l.addError(line, column, "unterminated multi-line string")
+			break
+		}
+		if l.char == '"' && l.peekChar() == '"' && l.peekCharAt(1) == '"' {
+			l.readChar()
+			l.readChar()
+			break
+		}
+
+		if l.char == '\\' {
+			raw = append(raw, l.readEscape()...)
+			continue
+		}
+		raw = append(raw, l.char)
+	}
+
+	return unescape([]rune(dedent(string(raw))))
+}
+
+// reads a string surrounded by backticks, ending on the closing backtick.
+// Nothing in a raw string is an escape, and it can span lines
+func (l *Lexer) readRawString() string {
+	line, column := l.line, l.column
+	var startPos int = l.position + 1
+
+	for {
+		l.readChar()
+		if l.char == 0 {
+			l.unterminated = true
+			l.addError(line, column, "unterminated raw string")
+			return string(l.input[startPos:l.position])
+		}
+		if l.char == '`' {
+			return string(l.input[startPos:l.position])
+		}
+	}
+}
+
+// reads the escape at the current backslash, recording an error if it
+// is invalid, and returns its characters as written. The lexer is left on
+// the last character of the escape
+func (l *Lexer) readEscape() []rune {
+	_, width, problem := escapeAt(l.input, l.position)
+	if problem != "" {
+		l.addError(l.line, l.column, problem)
+	}
+
+	escape := l.input[l.position:l.position + width]
+	for i := 1; i < width; i++ {
+		l.readChar()
+	}
+	return escape
+}
+
+// works out the escape starting at the backslash at input[start]. Returns
+// the character it stands for and how many characters it takes up. An
+// invalid escape returns a message saying why instead of a character
+func escapeAt(input []rune, start int) (rune, int, string) {
+	if start + 1 >= len(input) {
+		return 0, 1, ""
+	}
+
+	switch input[start + 1] {
+	case 'n':
+		return '\n', 2, ""
+	case 'r':
+		return '\r', 2, ""
+	case 't':
+		return '\t', 2, ""
+	case '0':
+		return 0, 2, ""
+	case '"':
+		return '"', 2, ""
+	case '\\':
+		return '\\', 2, ""
+	case '$':
+		return '$', 2, ""
+	case 'x':
+		return hexEscape(input, start, 2)
+	case 'u':
+		return hexEscape(input, start, 4)
+	}
+
+	return 0, 2, fmt.Sprintf("invalid escape \\%c in string", input[start + 1])
+}
+
+// works out an escape like \xHH or \uXXXX with the given number of hex
+// digits
+func hexEscape(input []rune, start int, digits int) (rune, int, string) {
+	var value rune = 0
+	var width int = 2
+
+	for width < 2 + digits && start + width < len(input) {
+		digit := hexValue(input[start + width])
+		if digit < 0 {
+			break
+		}
+		value = value * 16 + digit
+		width++
+	}
+
+	escape := string(input[start:start + 2])
+	if width < 2 + digits {
+		return 0, width, fmt.Sprintf("%s escape needs %d hex digits", escape, digits)
+	}
+	if !utf8.ValidRune(value) {
+		return 0, width, fmt.Sprintf("%s escape is not a valid code point",
+			string(input[start:start + width]))
+	}
+
+	return value, width, ""
+}
+
+func hexValue(char rune) rune {
+	switch {
+	case '0' <= char && char <= '9':
+		return char - '0'
+	case 'a' <= char && char <= 'f':
+		return char - 'a' + 10
+	case 'A' <= char && char <= 'F':
+		return char - 'A' + 10
+	}
+	return -1
+}
+
+// replaces the escapes in the text of a string. Invalid escapes, which
+// have already been reported, are kept as they are
+func unescape(raw []rune) string {
+	var out strings.Builder
+
+	for i := 0; i < len(raw); {
+		if raw[i] != '\\' {
+			out.WriteRune(raw[i])
+			i++
+			continue
+		}
+
+		char, width, problem := escapeAt(raw, i)
+		if problem != "" || width == 1 {
+			out.WriteString(string(raw[i:i + width]))
+		} else {
+			out.WriteRune(char)
+		}
+		i += width
+	}
+
+	return out.String()
+}
+
+// removes the first newline, a last line of only indentation, and the
+// indentation every line that is not blank starts with
+func dedent(text string) string {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+
+	lines := strings.Split(text, "\n")
+	if len(lines) > 1 && strings.TrimSpace(lines[len(lines) - 1]) == "" {
+		lines = lines[:len(lines) - 1]
+	}
+
+	var indent string
+	var found bool = false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		leading := line[:len(line) - len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			indent = leading
+			found = true
+			continue
+		}
+		for !strings.HasPrefix(leading, indent) {
+			indent = indent[:len(indent) - 1]
+		}
+	}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = strings.TrimPrefix(line, indent)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
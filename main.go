@@ -3,12 +3,19 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
 	"time"
+	"mylang/analyzer"
 	"mylang/ast"
 	"mylang/compiler"
+	"mylang/conformance"
+	"mylang/diagnostic"
 	"mylang/evaluator"
+	"mylang/formatter"
 	"mylang/lexer"
 	"mylang/object"
 	"mylang/parser"
@@ -16,76 +23,511 @@ import (
 	"mylang/vm"
 )
 
-var engine *string = flag.String("engine", "vm", "use 'vm' or 'eval'")
-var input *string = flag.String("file", "repl", "use filename")
-var benchmark *string = flag.String("bench", "no", "use 'yes' or 'no'")
+const USAGE = `usage: mylang <command> [flags] [arguments]
+
+commands:
+	run file [args]   runs a script, the same as "mylang file [args]"
+	repl              starts the interactive repl, the default
+	fmt files         formats the files, see "mylang fmt -h"
+	test [paths]      runs the *_test.ml files found in the paths
+	disasm file       prints the bytecode of a script
+	check files       reports problems in the files without running them
+	conformance       runs programs on both engines and reports differences
+
+run "mylang <command> -h" for the flags of a command
+`
+
+// exit statuses. Scripts can exit with their own status with the exit
+// builtin
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1
+	EXIT_USAGE = 2
+)
+
+var commands = map[string]func(args []string) int{
+	"run":    runCommand,
+	"repl":   replCommand,
+	"fmt":    fmtCommand,
+	"test":   testCommand,
+	"disasm": disasmCommand,
+	"check":  checkCommand,
+	"conformance": conformanceCommand,
+}
 
 func main() {
-	flag.Parse()
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// picks the command from the first argument. Anything that is not a
+// command is a script to run, so "#!/usr/bin/env mylang" works
+func dispatch(args []string) int {
+	if len(args) == 0 {
+		return replCommand(args)
+	}
 
-	var inputFile []byte
-	var result object.Object
-	var duration time.Duration
+	if command, ok := commands[args[0]]; ok {
+		return command(args[1:])
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Print(USAGE)
+		return EXIT_OK
+	}
+
+	return runCommand(args)
+}
 
-	if *input == "repl" {
-		user, err := user.Current()
+// the flags shared by the commands that run code
+type options struct {
+	engine *string
+	maxStack *int
+	maxFrames *int
+	maxGlobals *int
+	maxAlloc *int
+	color *bool
+	werror *bool
+	sandbox *string
+}
+
+func newFlagSet(name string, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: mylang %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func addOptions(flags *flag.FlagSet) *options {
+	return &options{
+		engine: flags.String("engine", "vm", "use 'vm' or 'eval'"),
+		maxStack: flags.Int("max-stack", vm.STACKSIZE, "stack slots the vm can grow to"),
+		maxFrames: flags.Int("max-frames", vm.MAXFRAMES, "number of nested calls"),
+		maxGlobals: flags.Int("max-globals", vm.GLOBALSIZE, "number of globals for the vm"),
+		maxAlloc: flags.Int("max-alloc", 0, "bytes the vm can allocate, 0 for no limit"),
+		color: flags.Bool("color", false, "color diagnostics with ANSI escapes"),
+		werror: flags.Bool("Werror", false, "treat warnings as errors"),
+		sandbox: flags.String("sandbox", "",
+			"restrict builtins to the comma separated grants 'exec', 'env', 'exit', and 'fs=<glob>', or 'none'"),
+	}
+}
+
+// sets up the sandbox and the evaluator from the options and returns the
+// limits for the vm
+func (o *options) apply() (vm.Limits, error) {
+	if *o.engine != "vm" && *o.engine != "eval" {
+		return vm.Limits{}, fmt.Errorf("unknown engine %q, use 'vm' or 'eval'", *o.engine)
+	}
+
+	if *o.sandbox != "" {
+		sandbox, err := object.ParseSandbox(*o.sandbox)
 		if err != nil {
-			panic(err)
+			return vm.Limits{}, fmt.Errorf("invalid sandbox: %s", err.Error())
+		}
+		object.SetSandbox(sandbox)
+	}
+
+	evaluator.MaxCallDepth = *o.maxFrames
+
+	return vm.Limits{
+		StackSize: *o.maxStack,
+		MaxFrames: *o.maxFrames,
+		GlobalsSize: *o.maxGlobals,
+		MaxAllocation: *o.maxAlloc,
+	}, nil
+}
+
+// parses the flags of a command, returning false and the exit status
+// when the command should not go on
+func parseFlags(flags *flag.FlagSet, args []string) (bool, int) {
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return false, EXIT_OK
+	}
+	if err != nil {
+		return false, EXIT_USAGE
+	}
+	return true, EXIT_OK
+}
+
+// a parsed script along with the renderer for its diagnostics
+type script struct {
+	path string
+	program *ast.Program
+	renderer *diagnostic.Renderer
+}
+
+// reads, parses and analyzes the file, printing any diagnostics to
+// stderr. Returns false if the file could not be read or has errors
+func load(path string, o *options) (*script, bool) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read: %s\n", err.Error())
+		return nil, false
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	var program *ast.Program = p.ParseProgram()
+	renderer := diagnostic.NewRenderer(path, string(source), *o.color)
+
+	diagnostics := analyzer.Analyze(program)
+	if *o.werror {
+		diagnostics = diagnostic.PromoteWarnings(diagnostics)
+	}
+	diagnostics = append(diagnostic.FromParseErrors(p.ParseErrors()), diagnostics...)
+
+	renderer.RenderAll(os.Stderr, diagnostics)
+	if diagnostic.HasErrors(diagnostics) {
+		return nil, false
+	}
+
+	return &script{path: path, program: program, renderer: renderer}, true
+}
+
+// compiles the script, printing the error to stderr if it fails
+func (s *script) compile() (*compiler.Bytecode, bool) {
+	comp := compiler.New()
+	err := comp.Compile(s.program)
+	if err != nil {
+		s.renderer.Render(os.Stderr, diagnostic.FromError(err, diagnostic.COMPILE_ERROR))
+		return nil, false
+	}
+	return comp.MakeBytecode(), true
+}
+
+// runs the script with the engine of the options, printing runtime
+// errors to stderr. Returns false if the script failed, and the exit the
+// script called if it ended with the exit builtin
+func (s *script) run(o *options, limits vm.Limits) (bool, *object.Exit) {
+	if *o.engine == "eval" {
+		result := evaluator.Evaluate(s.program, object.NewEnvironment())
+
+		if exit, ok := result.(*object.Exit); ok {
+			return true, exit
+		}
+		if errObj, ok := result.(*object.Error); ok {
+			s.renderer.Render(os.Stderr, diagnostic.FromErrorObject(errObj))
+			return false, nil
 		}
+		return true, nil
+	}
 
+	bytecode, ok := s.compile()
+	if !ok {
+		return false, nil
+	}
+
+	machine := vm.NewWithLimits(bytecode, limits)
+	err := machine.Run()
+	if exit, ok := err.(*object.Exit); ok {
+		return true, exit
+	}
+	if err != nil {
+		s.renderer.Render(os.Stderr, diagnostic.FromError(err, diagnostic.RUNTIME_ERROR,
+			"raised in " + machine.LastFrame()))
+		return false, nil
+	}
+	return true, nil
+}
+
+// mylang run [flags] file [args]
+func runCommand(args []string) int {
+	flags := newFlagSet("run", "file [args]")
+	o := addOptions(flags)
+	timing := flags.Bool("time", false, "print how long the script took to stderr")
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	limits, err := o.apply()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return EXIT_USAGE
+	}
+
+	object.Arguments = flags.Args()[1:]
+
+	s, ok := load(flags.Arg(0), o)
+	if !ok {
+		return EXIT_ERROR
+	}
+
+	start := time.Now()
+	ok, exit := s.run(o, limits)
+	if *timing {
+		fmt.Fprintf(os.Stderr, "duration=%s\n", time.Since(start))
+	}
+
+	if exit != nil {
+		return exit.Status
+	}
+	if !ok {
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// mylang repl [flags]
+func replCommand(args []string) int {
+	flags := newFlagSet("repl", "")
+	o := addOptions(flags)
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	limits, err := o.apply()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return EXIT_USAGE
+	}
+
+	user, err := user.Current()
+	if err == nil {
 		fmt.Printf("Hello %s, this is mylang\n", user.Username)
-		repl.Start(os.Stdin, os.Stdout)
-		
-		return
-	} else {
-		var err error
-		inputFile, err = os.ReadFile(*input)
+	}
+
+	repl.Color = *o.color
+	repl.Engine = *o.engine
+	repl.Limits = limits
+	repl.Start(os.Stdin, os.Stdout)
+
+	return EXIT_OK
+}
+
+// mylang fmt [-check | -write] files. Prints the files formatted, or with
+// -check lists the files that are not formatted and fails if there are
+// any, or with -write formats the files in place
+func fmtCommand(args []string) int {
+	flags := newFlagSet("fmt", "files")
+	color := flags.Bool("color", false, "color diagnostics with ANSI escapes")
+	check := flags.Bool("check", false, "list the files that are not formatted")
+	write := flags.Bool("write", false, "write the formatted source back to the files")
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	if *check && *write {
+		fmt.Fprintln(os.Stderr, "-check and -write can not be used together")
+		return EXIT_USAGE
+	}
+
+	var status int = EXIT_OK
+
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
 		if err != nil {
-			fmt.Printf("could not read: %s\n", err.Error())
-			return
+			fmt.Fprintf(os.Stderr, "could not read: %s\n", err.Error())
+			status = EXIT_ERROR
+			continue
+		}
+
+		formatted, parseErrors := formatter.Format(string(source))
+		if len(parseErrors) != 0 {
+			renderer := diagnostic.NewRenderer(path, string(source), *color)
+			renderer.RenderAll(os.Stderr, diagnostic.FromParseErrors(parseErrors))
+			status = EXIT_ERROR
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != string(source) {
+				fmt.Println(path)
+				status = EXIT_ERROR
+			}
+		case *write:
+			if formatted == string(source) {
+				continue
+			}
+			err := os.WriteFile(path, []byte(formatted), 0644)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not write: %s\n", err.Error())
+				status = EXIT_ERROR
+			}
+		default:
+			fmt.Print(formatted)
 		}
 	}
 
-	l := lexer.New(string(inputFile))
-	p := parser.New(l)
-	var program *ast.Program = p.ParseProgram()
-	if len(p.Errors()) > 0 {
-		repl.PrintParseErrors(os.Stdout, p.Errors())	
+	return status
+}
+
+// mylang test [flags] [paths]. Runs every file ending in _test.ml in the
+// given files and directories, the current directory by default. A test
+// fails when it does not parse, raises an error, or calls exit, since the
+// rest of the test was skipped
+func testCommand(args []string) int {
+	flags := newFlagSet("test", "[paths]")
+	o := addOptions(flags)
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	limits, err := o.apply()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return EXIT_USAGE
 	}
 
-	if *engine == "vm" {
-		comp := compiler.New()
-		err := comp.Compile(program)
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files := []string{}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && (file == path || strings.HasSuffix(file, "_test.ml")) {
+				files = append(files, file)
+			}
+			return nil
+		})
 		if err != nil {
-			fmt.Printf("compile error: %s", err)
-			return
+			fmt.Fprintln(os.Stderr, err.Error())
+			return EXIT_ERROR
 		}
+	}
+
+	object.Arguments = []string{}
+	var failed int = 0
 
-		machine := vm.New(comp.MakeBytecode())
+	for _, file := range files {
 		start := time.Now()
 
-		err = machine.Run()
-		if err != nil {
-			fmt.Printf("virtual machine error %s, at %s\n", err, machine.LastFrame())
-			return
+		s, ok := load(file, o)
+		if ok {
+			var exit *object.Exit
+			ok, exit = s.run(o, limits)
+			if exit != nil {
+				fmt.Fprintf(os.Stderr, "%s: exit(%d) called before the end of the test\n",
+					file, exit.Status)
+				ok = false
+			}
+		}
+
+		if ok {
+			fmt.Printf("ok   %s (%s)\n", file, time.Since(start))
+		} else {
+			fmt.Printf("FAIL %s (%s)\n", file, time.Since(start))
+			failed++
 		}
+	}
 
-		duration = time.Since(start)
-		result = machine.LastPoppedStackElement()
+	if failed > 0 {
+		fmt.Printf("%d of %d tests failed\n", failed, len(files))
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// mylang disasm file
+func disasmCommand(args []string) int {
+	flags := newFlagSet("disasm", "file")
+	o := addOptions(flags)
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	s, ok := load(flags.Arg(0), o)
+	if !ok {
+		return EXIT_ERROR
+	}
+
+	bytecode, ok := s.compile()
+	if !ok {
+		return EXIT_ERROR
+	}
+
+	fmt.Print(bytecode.Disassemble(0))
+	return EXIT_OK
+}
+
+// mylang check files. Parses, analyzes and compiles the files without
+// running them
+func checkCommand(args []string) int {
+	flags := newFlagSet("check", "files")
+	o := addOptions(flags)
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	var status int = EXIT_OK
+
+	for _, path := range flags.Args() {
+		s, ok := load(path, o)
+		if ok {
+			_, ok = s.compile()
+		}
+		if !ok {
+			status = EXIT_ERROR
+		}
+	}
+
+	return status
+}
+
+// mylang conformance [-v] [paths]. Runs the programs in the given files
+// and directories, or the corpus built into mylang, on both engines
+func conformanceCommand(args []string) int {
+	flags := newFlagSet("conformance", "[paths]")
+	verbose := flags.Bool("v", false, "list the cases that pass too")
+	if ok, status := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	var cases []*conformance.Case
+	var err error
+	if flags.NArg() == 0 {
+		cases, err = conformance.Corpus()
 	} else {
-		env := object.NewEnvironment()
-		start := time.Now()
-		result = evaluator.Evaluate(program, env)
-		duration = time.Since(start)
+		cases, err = conformance.Load(flags.Args())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return EXIT_ERROR
 	}
 
-	fmt.Printf(
-		"engine=%s, result=%s\n",
-		*engine,
-		result.Inspect(),
-	)
+	var failed int = 0
+
+	for _, c := range cases {
+		failures := c.Run()
+
+		if len(failures) == 0 {
+			if *verbose {
+				fmt.Printf("ok   %s\n", c.Name)
+			}
+			continue
+		}
+
+		failed++
+		fmt.Printf("FAIL %s\n", c.Name)
+		for _, failure := range failures {
+			fmt.Printf("     %s\n", failure)
+		}
+	}
 
-	if *benchmark == "yes" {
-		fmt.Printf("duration=%s\n", duration)
+	fmt.Printf("%d of %d cases passed\n", len(cases) - failed, len(cases))
+	if failed > 0 {
+		return EXIT_ERROR
 	}
+	return EXIT_OK
 }
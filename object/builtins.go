@@ -4,12 +4,16 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -18,6 +22,18 @@ var (
 	NULL = &Null{}
 )
 
+// the most bytes of a string built by repeat, padLeft, padRight, replace
+// and join. The size is checked before the string is built, so a count
+// that is far too large fails instead of using up memory before the vm
+// can count it against its allocation limit
+const MAX_STRING_SIZE = 1 << 28
+
+// the arguments given to the script, returned by the args builtin
+var Arguments []string = os.Args[1:]
+
+// where puts writes, replaced to capture the output of a script
+var Stdout io.Writer = os.Stdout
+
 var Builtins = []struct {
 	Name    string
 	Builtin *Builtin
@@ -34,7 +50,9 @@ var Builtins = []struct {
 			case *Array:
 				return &Integer{Value: int64(len(arg.Elements))}
 			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
+				return &Integer{Value: int64(arg.Len())}
+			case *Builder:
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Buffer.String()))}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -45,7 +63,7 @@ var Builtins = []struct {
 		"puts",
 		&Builtin{Function: func(args ...Object) Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(Stdout, arg.Inspect())
 			}
 
 			return NULL
@@ -157,19 +175,18 @@ var Builtins = []struct {
 
 				array.Elements = array.Elements[:length - 1]
 				return out
+			// strings can not change, so popping one returns a new string
+			// without its last character
 			case STRING_OBJ:
-				str := args[0].(*String)
-				length := int64(len(str.Value))
+				runes := args[0].(*String).Runes()
+				length := len(runes)
 				if length == 0 {
 					return NULL
 				}
 
-				s := str.Value[length - 1]
-				str.Value = str.Value[:length - 1]
-
-				return &String{Value: string(s)}
+				return &String{Value: string(runes[:length - 1])}
 			default:
-				return newError("argument to `assign` must be ARRAY or STRING, got %s",
+				return newError("argument to `pop` must be ARRAY or STRING, got %s",
 					args[0].Type())
 			}
 		}},
@@ -198,12 +215,9 @@ var Builtins = []struct {
 			}
 
 			hashObj := args[0].(*Hash)
-			newElements := make([]Object, len(hashObj.Pairs))
-			var i int = 0
-
-			for _, pair := range hashObj.Pairs {
-				newElements[i] = pair.Key
-				i += 1
+			newElements := []Object{}
+			for _, pair := range hashObj.Pairs() {
+				newElements = append(newElements, pair.Key)
 			}
 
 			return &Array{Elements: newElements}
@@ -229,8 +243,7 @@ var Builtins = []struct {
 
 			hashObj := args[0].(*Hash)
 
-			hashKey := key.HashKey()
-			delete(hashObj.Pairs, hashKey)
+			hashObj.Delete(key)
 			
 			return NULL
 		}},
@@ -267,7 +280,7 @@ var Builtins = []struct {
 				}
 
 				hash := args[0].(*Hash)
-				hash.Pairs[hashKey.HashKey()] = HashPair{Key: args[1], Value: args[2]}
+				hash.Set(hashKey, args[2])
 
 			case STRING_OBJ:
 				if args[1].Type() != INTEGER_OBJ {
@@ -279,16 +292,18 @@ var Builtins = []struct {
 						args[2].Type())
 				}
 
-				str := args[0].(*String)
-				length := int64(len(str.Value))
+				runes := args[0].(*String).Runes()
+				length := int64(len(runes))
 				index := args[1].(*Integer).Value
 				insert := args[2].(*String).Value
 
-				if (index > length - 1) {
+				if (index < 0 || index > length - 1) {
 					return newError("invalid index on string")
 				}
 
-				str.Value = str.Value[:index] + insert + str.Value[index + 1:] 
+				// strings can not change, the string with the character
+				// replaced is returned instead
+				return &String{Value: string(runes[:index]) + insert + string(runes[index + 1:])}
 			default:
 				return newError("argument 1 to `assign` must be ARRAY, HASH, or STRING got %s",
 					args[0].Type())
@@ -320,6 +335,9 @@ var Builtins = []struct {
 				return newError("argument to `command` must be STRING. got=%q",
 					args[0].Type())
 			}
+			if err := checkCapability(EXEC_CAP, "command"); err != nil {
+				return err
+			}
 
 			r := regexp.MustCompile(`[^\s"']+|"([^"]*)"|'([^']*)`)
 			res := r.FindAllString(str.Value, -1)
@@ -348,17 +366,11 @@ var Builtins = []struct {
 			stdout := &String{Value: outb.String()}
 			stderr := &String{Value: errb.String()}
 
-			stdoutKey := &String{Value: "stdout"}
-			stdoutPair := HashPair{Key: stdoutKey, Value: stdout}
-
-			stderrKey := &String{Value: "stderr"}
-			stderrPair := HashPair{Key: stderrKey, Value: stderr}
-
-			newHash := make(map[HashKey]HashPair)
-			newHash[stdoutKey.HashKey()] = stdoutPair
-			newHash[stderrKey.HashKey()] = stderrPair
+			newHash := NewHash()
+			newHash.Set(&String{Value: "stdout"}, stdout)
+			newHash.Set(&String{Value: "stderr"}, stderr)
 
-			return &Hash{Pairs: newHash}
+			return newHash
 		}},
 	},
 	{
@@ -383,6 +395,9 @@ var Builtins = []struct {
 			}
 
 			var path string = args[0].(*String).Value
+			if err := checkPath(path, "open"); err != nil {
+				return err
+			}
 
 			md := os.O_RDONLY
 
@@ -491,6 +506,9 @@ var Builtins = []struct {
 			}
 
 			file := args[0].(*File)
+			if err := checkPath(file.Path, "remove"); err != nil {
+				return err
+			}
 
 			err := os.Remove(file.Path)
 			if err != nil {
@@ -503,12 +521,15 @@ var Builtins = []struct {
 	{
 		"args",
 		&Builtin{Function: func(args ...Object) Object {
+			if err := checkCapability(ENV_CAP, "args"); err != nil {
+				return err
+			}
+
 			switch len(args) {
 			case 0:
-				length := len(os.Args[1:])
-				out := make([]Object, length)
-				for i, arg := range os.Args[1:] {
-				out[i] = &String{Value: arg}
+				out := make([]Object, len(Arguments))
+				for i, arg := range Arguments {
+					out[i] = &String{Value: arg}
 				}
 				return &Array{Elements: out}
 			case 1:
@@ -517,11 +538,10 @@ var Builtins = []struct {
 						args[0].Type())
 				}
 				index := args[0].(*Integer).Value
-				osArgs := os.Args[1:]
-				if index > int64(len(osArgs)) - 1 || index < 0 {
+				if index > int64(len(Arguments)) - 1 || index < 0 {
 					return newError("out of bounds index")
 				}
-				return &String{Value: osArgs[index]}
+				return &String{Value: Arguments[index]}
 			default:
 				return newError("wrong number of arguments. got=%d, want 0 or 1",
 					len(args))
@@ -605,6 +625,488 @@ var Builtins = []struct {
 			return &Float{Value: rand.Float64()}
 		}},
 	},
+	{
+		"exit",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkCapability(EXIT_CAP, "exit"); err != nil {
+				return err
+			}
+
+			var code int64 = 0
+
+			switch len(args) {
+			case 0:
+			case 1:
+				if args[0].Type() != INTEGER_OBJ {
+					return newError("argument to `exit` must be INTEGER. got=%s",
+						args[0].Type())
+				}
+				code = args[0].(*Integer).Value
+			default:
+				return newError("wrong number of arguments to `exit`. got=%d, want 0 or 1",
+					len(args))
+			}
+
+			return &Exit{Status: int(code)}
+		}},
+	},
+	{
+		"values",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `values`. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != HASH_OBJ {
+				return newError("argument to `values` must be HASH, got %s",
+					args[0].Type())
+			}
+
+			newElements := []Object{}
+			for _, pair := range args[0].(*Hash).Pairs() {
+				newElements = append(newElements, pair.Value)
+			}
+
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		// the pairs of a hash as [key, value] arrays
+		"items",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `items`. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != HASH_OBJ {
+				return newError("argument to `items` must be HASH, got %s",
+					args[0].Type())
+			}
+
+			newElements := []Object{}
+			for _, pair := range args[0].(*Hash).Pairs() {
+				newElements = append(newElements, &Array{Elements: []Object{pair.Key, pair.Value}})
+			}
+
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		"builder",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments to `builder`. got=%d, want=0",
+					len(args))
+			}
+
+			return &Builder{}
+		}},
+	},
+	{
+		// adds each value to the end of a builder, strings as they are and
+		// anything else as it prints, and returns the builder
+		"append",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments to `append`. got=%d, want at least 1",
+					len(args))
+			}
+			builder, ok := args[0].(*Builder)
+			if !ok {
+				return newError("argument 1 to `append` must be BUILDER, got %s",
+					args[0].Type())
+			}
+
+			for _, arg := range args[1:] {
+				builder.Buffer.WriteString(arg.Inspect())
+			}
+
+			return builder
+		}},
+	},
+	{
+		// the bytes of the utf-8 encoding of a string, as integers
+		"bytes",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `bytes`. got=%d, want=1",
+					len(args))
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `bytes` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			newElements := make([]Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				newElements[i] = &Integer{Value: int64(str.Value[i])}
+			}
+
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		// the code points of a string, as integers
+		"runes",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `runes`. got=%d, want=1",
+					len(args))
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `runes` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			runes := str.Runes()
+			newElements := make([]Object, len(runes))
+			for i, r := range runes {
+				newElements[i] = &Integer{Value: int64(r)}
+			}
+
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		// splits a string around each separator, or into its characters
+		// when the separator is empty
+		"split",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("split", args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*String)
+			separator := args[1].(*String).Value
+
+			newElements := []Object{}
+			if separator == "" {
+				for _, r := range str.Runes() {
+					newElements = append(newElements, &String{Value: string(r)})
+				}
+				return &Array{Elements: newElements}
+			}
+
+			for _, part := range strings.Split(str.Value, separator) {
+				newElements = append(newElements, &String{Value: part})
+			}
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		// joins the elements of an array with the separator between them,
+		// strings as they are and anything else as it prints
+		"join",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("join", args, ARRAY_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			separator := args[1].(*String).Value
+
+			parts := []string{}
+			size := 0
+			for i, element := range args[0].(*Array).Elements {
+				part := element.Inspect()
+				parts = append(parts, part)
+
+				size += len(part)
+				if i > 0 {
+					size += len(separator)
+				}
+				if size > MAX_STRING_SIZE {
+					return newError("result of `join` is too large, more than %d bytes",
+						MAX_STRING_SIZE)
+				}
+			}
+
+			return &String{Value: strings.Join(parts, separator)}
+		}},
+	},
+	{
+		"trim",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("trim", args, STRING_OBJ); err != nil {
+				return err
+			}
+			return &String{Value: strings.TrimSpace(args[0].(*String).Value)}
+		}},
+	},
+	{
+		"trimLeft",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("trimLeft", args, STRING_OBJ); err != nil {
+				return err
+			}
+			return &String{Value: strings.TrimLeftFunc(args[0].(*String).Value, unicode.IsSpace)}
+		}},
+	},
+	{
+		"trimRight",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("trimRight", args, STRING_OBJ); err != nil {
+				return err
+			}
+			return &String{Value: strings.TrimRightFunc(args[0].(*String).Value, unicode.IsSpace)}
+		}},
+	},
+	{
+		// replaces every occurrence of the second string with the third
+		"replace",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("replace", args, STRING_OBJ, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*String).Value
+			old := args[1].(*String).Value
+			replacement := args[2].(*String).Value
+
+			// an empty string is found before every character and at
+			// the end, which Count counts the same way
+			growth := len(replacement) - len(old)
+			if growth > 0 {
+				count := strings.Count(str, old)
+				if count > 0 && count > (MAX_STRING_SIZE - len(str)) / growth {
+					return newError("result of `replace` is too large, more than %d bytes",
+						MAX_STRING_SIZE)
+				}
+			}
+
+			return &String{Value: strings.ReplaceAll(str, old, replacement)}
+		}},
+	},
+	{
+		"contains",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("contains", args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+			if strings.Contains(args[0].(*String).Value, args[1].(*String).Value) {
+				return TRUE
+			}
+			return FALSE
+		}},
+	},
+	{
+		"startsWith",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("startsWith", args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+			if strings.HasPrefix(args[0].(*String).Value, args[1].(*String).Value) {
+				return TRUE
+			}
+			return FALSE
+		}},
+	},
+	{
+		"endsWith",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("endsWith", args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+			if strings.HasSuffix(args[0].(*String).Value, args[1].(*String).Value) {
+				return TRUE
+			}
+			return FALSE
+		}},
+	},
+	{
+		// the index of the first occurrence of the second string, counted
+		// in characters like string indexes, or -1 when there is none
+		"indexOf",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("indexOf", args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*String).Value
+			index := strings.Index(str, args[1].(*String).Value)
+			if index < 0 {
+				return &Integer{Value: -1}
+			}
+
+			return &Integer{Value: int64(utf8.RuneCountInString(str[:index]))}
+		}},
+	},
+	{
+		"upper",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("upper", args, STRING_OBJ); err != nil {
+				return err
+			}
+			return &String{Value: strings.ToUpper(args[0].(*String).Value)}
+		}},
+	},
+	{
+		"lower",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("lower", args, STRING_OBJ); err != nil {
+				return err
+			}
+			return &String{Value: strings.ToLower(args[0].(*String).Value)}
+		}},
+	},
+	{
+		"repeat",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("repeat", args, STRING_OBJ, INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*String).Value
+			count := args[1].(*Integer).Value
+			if count < 0 {
+				return newError("argument 2 to `repeat` must not be negative, got %d", count)
+			}
+			if len(str) > 0 && count > int64(MAX_STRING_SIZE / len(str)) {
+				return newError("result of `repeat` is too large, more than %d bytes",
+					MAX_STRING_SIZE)
+			}
+
+			return &String{Value: strings.Repeat(str, int(count))}
+		}},
+	},
+	{
+		// the characters of a string from start, up to the end or as many
+		// as the optional length. A length past the end stops at the end
+		"substr",
+		&Builtin{Function: func(args ...Object) Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments to `substr`. got=%d, want 2 or 3",
+					len(args))
+			}
+			types := []ObjectType{STRING_OBJ, INTEGER_OBJ, INTEGER_OBJ}
+			if err := checkArguments("substr", args, types[:len(args)]...); err != nil {
+				return err
+			}
+
+			runes := args[0].(*String).Runes()
+			start := args[1].(*Integer).Value
+			if start < 0 || start > int64(len(runes)) {
+				return newError("start of `substr` out of range, got %d for length %d",
+					start, len(runes))
+			}
+
+			end := int64(len(runes))
+			if len(args) == 3 {
+				length := args[2].(*Integer).Value
+				if length < 0 {
+					return newError("length of `substr` must not be negative, got %d", length)
+				}
+				if length < end - start {
+					end = start + length
+				}
+			}
+
+			return &String{Value: string(runes[start:end])}
+		}},
+	},
+	{
+		// the lines of a string, without their line endings. A newline at
+		// the end does not start another line
+		"lines",
+		&Builtin{Function: func(args ...Object) Object {
+			if err := checkArguments("lines", args, STRING_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*String).Value
+			newElements := []Object{}
+			if str == "" {
+				return &Array{Elements: newElements}
+			}
+
+			for _, line := range strings.Split(strings.TrimSuffix(str, "\n"), "\n") {
+				newElements = append(newElements, &String{Value: strings.TrimSuffix(line, "\r")})
+			}
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		// pads a string to the width with spaces, or the optional padding
+		// string, put before it
+		"padLeft",
+		&Builtin{Function: func(args ...Object) Object {
+			return pad("padLeft", args, true)
+		}},
+	},
+	{
+		"padRight",
+		&Builtin{Function: func(args ...Object) Object {
+			return pad("padRight", args, false)
+		}},
+	},
+}
+
+// checks the number and types of the arguments of a builtin. Returns the
+// error the builtin gives back when they are wrong, or nil
+func checkArguments(name string, args []Object, types ...ObjectType) *Error {
+	if len(args) != len(types) {
+		return newError("wrong number of arguments to `%s`. got=%d, want=%d",
+			name, len(args), len(types))
+	}
+
+	for i, t := range types {
+		if args[i].Type() == t {
+			continue
+		}
+		if len(types) == 1 {
+			return newError("argument to `%s` must be %s, got %s", name, t, args[i].Type())
+		}
+		return newError("argument %d to `%s` must be %s, got %s", i + 1, name, t, args[i].Type())
+	}
+
+	return nil
+}
+
+// pads the string in args to a width in characters, on the left or the
+// right, repeating the padding and cutting it to fit
+func pad(name string, args []Object, left bool) Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("wrong number of arguments to `%s`. got=%d, want 2 or 3",
+			name, len(args))
+	}
+	types := []ObjectType{STRING_OBJ, INTEGER_OBJ, STRING_OBJ}
+	if err := checkArguments(name, args, types[:len(args)]...); err != nil {
+		return err
+	}
+
+	str := args[0].(*String)
+	width := args[1].(*Integer).Value
+	padding := []rune(" ")
+	if len(args) == 3 {
+		padding = args[2].(*String).Runes()
+		if len(padding) == 0 {
+			return newError("padding of `%s` must not be empty", name)
+		}
+	}
+
+	missing := width - int64(str.Len())
+	if missing <= 0 {
+		return str
+	}
+
+	// the padding is repeated whole as many times as it fits, and then
+	// the characters of it that are still missing
+	repeats := missing / int64(len(padding))
+	rest := string(padding[:missing % int64(len(padding))])
+	whole := string(padding)
+
+	size := int64(len(str.Value)) + int64(len(rest))
+	if missing > MAX_STRING_SIZE || size + repeats * int64(len(whole)) > MAX_STRING_SIZE {
+		return newError("result of `%s` is too large, more than %d bytes",
+			name, MAX_STRING_SIZE)
+	}
+
+	fill := strings.Repeat(whole, int(repeats)) + rest
+	if left {
+		return &String{Value: fill + str.Value}
+	}
+	return &String{Value: str.Value + fill}
 }
 
 func newError(format string, a ...interface{}) *Error {
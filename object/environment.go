@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
@@ -29,6 +31,17 @@ func (e *Environment) Set(name string, value Object) Object {
 	return value
 }
 
+// returns the names defined in this environment, not counting the outer
+// ones, sorted
+func (e *Environment) Names() []string {
+	names := []string{}
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (e *Environment) Assign(name string, value Object) bool {
 	_, ok := e.store[name]
 
@@ -0,0 +1,82 @@
+package object
+
+// a pair of containers being compared, used to stop at cycles
+type comparison struct {
+	left Object
+	right Object
+}
+
+// reports whether two values are equal. Integers, floats, strings and
+// booleans are equal when their values are, arrays when their elements
+// are equal in order, and hashes when they have the same keys with
+// equal values. Anything else, like functions, is only equal to itself.
+// Values of different types are never equal
+func Equal(left, right Object) bool {
+	return equal(left, right, make(map[comparison]bool))
+}
+
+// compares the values, with seen holding the containers already being
+// compared further up. Meeting the same pair again means the values
+// contain themselves in the same way, which counts as equal
+func equal(left, right Object, seen map[comparison]bool) bool {
+	if left == right {
+		return true
+	}
+	if left == nil || right == nil || left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *Integer:
+		return left.Value == right.(*Integer).Value
+	case *Float:
+		return left.Value == right.(*Float).Value
+	case *String:
+		return left.Value == right.(*String).Value
+	case *Boolean:
+		return left.Value == right.(*Boolean).Value
+	case *Null:
+		return true
+
+	case *Array:
+		r := right.(*Array)
+		if len(left.Elements) != len(r.Elements) {
+			return false
+		}
+
+		pair := comparison{left, right}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+
+		for i := range left.Elements {
+			if !equal(left.Elements[i], r.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+
+	case *Hash:
+		r := right.(*Hash)
+		if left.Len() != r.Len() {
+			return false
+		}
+
+		pair := comparison{left, right}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+
+		for _, leftPair := range left.Pairs() {
+			rightPair, ok := r.Get(leftPair.Key.(Hashable))
+			if !ok || !equal(leftPair.Value, rightPair.Value, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package object
+
+// the fewest holes a hash compacts, so small hashes that keep deleting
+// and setting the same keys do not copy their pairs every time
+const MIN_COMPACT = 8
+
+// a hash that keeps its pairs in the order their keys were first set.
+// index maps each HashKey to the positions in pairs of the keys with
+// that HashKey, usually just one, and keys that collide are told apart
+// with sameKey, so lookups stay O(1). Deleting a pair leaves a nil hole
+// in pairs, and the holes are squeezed out once they make up half of it,
+// which keeps deletes O(1) on average. The zero value is an empty hash
+type Hash struct {
+	index map[HashKey][]int
+	pairs []*HashPair
+	holes int
+}
+
+func NewHash() *Hash {
+	return &Hash{index: make(map[HashKey][]int), pairs: []*HashPair{}}
+}
+
+// reports whether two hashable values are the same key. Floats are the
+// same key when their bits are, -0.0 aside, so a NaN key can be found
+// again even though NaN is not equal to itself
+func sameKey(a, b Hashable) bool {
+	if left, ok := a.(*Float); ok {
+		right, ok := b.(*Float)
+		return ok && floatBits(left.Value) == floatBits(right.Value)
+	}
+	return Equal(a, b)
+}
+
+// returns the position of the key in pairs, or -1
+func (h *Hash) find(key Hashable, hashKey HashKey) int {
+	for _, position := range h.index[hashKey] {
+		if sameKey(h.pairs[position].Key.(Hashable), key) {
+			return position
+		}
+	}
+	return -1
+}
+
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	position := h.find(key, key.HashKey())
+	if position < 0 {
+		return HashPair{}, false
+	}
+	return *h.pairs[position], true
+}
+
+// sets the value of the key. A key that is already in the hash keeps its
+// place, a new key goes at the end
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.index == nil {
+		h.index = make(map[HashKey][]int)
+	}
+
+	hashKey := key.HashKey()
+	if position := h.find(key, hashKey); position >= 0 {
+		h.pairs[position].Value = value
+		return
+	}
+
+	h.index[hashKey] = append(h.index[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, &HashPair{Key: key, Value: value})
+}
+
+// removes the key and reports whether it was in the hash
+func (h *Hash) Delete(key Hashable) bool {
+	hashKey := key.HashKey()
+	position := h.find(key, hashKey)
+	if position < 0 {
+		return false
+	}
+
+	bucket := h.index[hashKey]
+	if len(bucket) == 1 {
+		delete(h.index, hashKey)
+	} else {
+		for i := range bucket {
+			if bucket[i] == position {
+				h.index[hashKey] = append(bucket[:i:i], bucket[i + 1:]...)
+				break
+			}
+		}
+	}
+
+	h.pairs[position] = nil
+	h.holes++
+
+	if h.holes >= MIN_COMPACT && h.holes * 2 >= len(h.pairs) {
+		h.compact()
+	}
+	return true
+}
+
+// moves the pairs over the holes, keeping their order
+func (h *Hash) compact() {
+	pairs := make([]*HashPair, 0, len(h.pairs) - h.holes)
+	index := make(map[HashKey][]int, len(h.index))
+	for _, pair := range h.pairs {
+		if pair != nil {
+			hashKey := pair.Key.(Hashable).HashKey()
+			index[hashKey] = append(index[hashKey], len(pairs))
+			pairs = append(pairs, pair)
+		}
+	}
+
+	h.pairs = pairs
+	h.index = index
+	h.holes = 0
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs) - h.holes
+}
+
+// the pairs of the hash in insertion order
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, h.Len())
+	for _, pair := range h.pairs {
+		if pair != nil {
+			pairs = append(pairs, *pair)
+		}
+	}
+	return pairs
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"os"
 	"strings"
 	"mylang/ast"
@@ -22,14 +23,18 @@ const (
 	BOOLEAN_OBJ = "BOOLEAN"
 	STRING_OBJ = "STRING"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	TAIL_CALL_OBJ = "TAIL_CALL"
 	FUNCTION_OBJ = "FUNCTION"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 	CLOSURE_OBJ = "CLOSURE"
+	UPVALUE_OBJ = "UPVALUE"
 	ERROR_OBJ = "ERROR"
+	EXIT_OBJ = "EXIT"
 	BUILTIN_OBJ = "BUILTIN"
 	ARRAY_OBJ = "ARRAY"
 	HASH_OBJ = "HASH"
 	FILE_OBJ = "FILE"
+	BUILDER_OBJ = "BUILDER"
 )
 
 // wrapper for values used by evaluator
@@ -38,7 +43,10 @@ type Object interface {
 	Inspect() string
 }
 
+// values that can be keys of a hash. Different values may have the same
+// HashKey, the hash tells them apart with sameKey
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -73,13 +81,54 @@ func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
 
 
+// strings never change once made, the compiler shares one String between
+// every use of a literal. Builtins that change a string return a new one.
+// Strings are measured and indexed by code point, see Runes
 type String struct {
 	Value string
+
+	// the code points of Value, worked out the first time they are needed
+	runes []rune
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string { return s.Value }
 
+// the code points of the string. Kept after the first call, which is
+// safe since the string never changes
+func (s *String) Runes() []rune {
+	if s.runes == nil {
+		s.runes = []rune(s.Value)
+	}
+	return s.runes
+}
+
+// the number of code points in the string
+func (s *String) Len() int {
+	return len(s.Runes())
+}
+
+
+// joins the values of an interpolated string, strings as they are and
+// anything else as it prints
+func Interpolate(values []Object) *String {
+	var out strings.Builder
+	for _, value := range values {
+		out.WriteString(value.Inspect())
+	}
+	return &String{Value: out.String()}
+}
+
+
+// a string that can be added to in place, for building long strings
+// without copying them on every step. Made by the builder builtin
+type Builder struct {
+	Buffer strings.Builder
+}
+
+func (b *Builder) Type() ObjectType { return BUILDER_OBJ }
+func (b *Builder) Inspect() string { return b.Buffer.String() }
+
 
 type File struct {
 	Path string
@@ -123,10 +172,6 @@ type HashPair struct {
 	Value Object
 }
 
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
@@ -143,11 +188,18 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// hashed from the bits of the value, with -0.0 taken as 0.0 since the
+// two are equal. Floats and integers are different keys even when they
+// hold the same number, just as 1 == 1.0 is false
 func (f *Float) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(f.Inspect()))
-	
-	return HashKey{Type: f.Type(), Value: h.Sum64()}
+	return HashKey{Type: f.Type(), Value: floatBits(f.Value)}
+}
+
+func floatBits(value float64) uint64 {
+	if value == 0 {
+		return 0
+	}
+	return math.Float64bits(value)
 }
 
 func (s *String) HashKey() HashKey {
@@ -163,7 +215,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -184,10 +236,23 @@ func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
 
+// a call in tail position that the evaluator has not made yet. It is
+// returned up to the function being applied, which makes the call in
+// its place so tail recursion does not grow the go stack
+type TailCall struct {
+	Function *Function
+	Arguments []Object
+}
+
+func (tc *TailCall) Type() ObjectType { return TAIL_CALL_OBJ }
+func (tc *TailCall) Inspect() string { return "tail call" }
+
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body *ast.BlockStatement
 	Environment *Environment
+	Name string
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -212,6 +277,7 @@ func (f *Function) Inspect() string {
 
 type CompiledFunction struct {
 	Instructions code.Instructions
+	Positions []code.Position
 	NumLocals int
 	NumParameters int
 	Name string
@@ -225,7 +291,7 @@ func (cf *CompiledFunction) Inspect() string {
 
 type Closure struct {
 	Function *CompiledFunction
-	Free []Object
+	Free []*Upvalue
 }
 
 func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
@@ -233,6 +299,21 @@ func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
 
+// a variable captured by a closure, shared by every closure that captures
+// it. While the function that defined the variable is running the upvalue
+// is open and the variable lives on the vm stack at Slot. When that
+// function returns the upvalue is closed and keeps the value itself
+type Upvalue struct {
+	Slot int
+	Open bool
+	Value Object
+}
+
+func (u *Upvalue) Type() ObjectType { return UPVALUE_OBJ }
+func (u *Upvalue) Inspect() string {
+	return fmt.Sprintf("Upvalue[%p]", u)
+}
+
 
 type Builtin struct {
 	Function BuiltinFunction
@@ -244,7 +325,65 @@ func (b *Builtin) Inspect() string { return "builtin function" }
 
 type Error struct {
 	Message string
+	// where in the source the error was raised, the evaluator sets it
+	// and leaves a Line of 0 when it is not known
+	Position code.Position
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+
+// returned by the exit builtin. Both engines stop running when they get
+// one, like they do for errors, and leave it to whoever ran the program
+// to end with the status. It is also an error so the vm can return it
+// from Run
+type Exit struct {
+	Status int
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+func (e *Exit) Inspect() string { return fmt.Sprintf("exit(%d)", e.Status) }
+func (e *Exit) Error() string { return fmt.Sprintf("exit status %d", e.Status) }
+
+// approximate number of bytes held by the object itself, not counting
+// the objects it refers to. Used by the vm to account for allocations
+func SizeOf(obj Object) int {
+	switch obj := obj.(type) {
+	case *String:
+		return 16 + len(obj.Value)
+	case *Builder:
+		return 24 + obj.Buffer.Cap()
+	case *Array:
+		return 24 + 16 * len(obj.Elements)
+	case *Hash:
+		return 48 + 64 * obj.Len()
+	case *Closure:
+		return 32 + 16 * len(obj.Free)
+	default:
+		return 16
+	}
+}
+
+// formats the names of the functions on a call stack, innermost call first,
+// collapsing repeated calls to the same function. Used by both engines to
+// report runaway recursion ie "countDown (x1023) <- <main>"
+func CallChain(names []string) string {
+	parts := []string{}
+
+	for i := 0; i < len(names); {
+		j := i
+		for j < len(names) && names[j] == names[i] {
+			j++
+		}
+
+		if j - i > 1 {
+			parts = append(parts, fmt.Sprintf("%s (x%d)", names[i], j - i))
+		} else {
+			parts = append(parts, names[i])
+		}
+
+		i = j
+	}
+
+	return strings.Join(parts, " <- ")
+}
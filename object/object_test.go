@@ -1,6 +1,7 @@
 package object
 
 import (
+	"math"
 	"testing"
 )
 
@@ -22,3 +23,193 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+// a string whose HashKey is always the same, to make keys collide
+type collidingString struct {
+	String
+}
+
+func (s *collidingString) HashKey() HashKey {
+	return HashKey{Type: STRING_OBJ, Value: 42}
+}
+
+func TestHashCollisions(t *testing.T) {
+	a := &collidingString{String{Value: "a"}}
+	b := &collidingString{String{Value: "b"}}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got=%s", hash.Inspect())
+	}
+
+	pair, ok := hash.Get(b)
+	if !ok || pair.Value.Inspect() != "2" {
+		t.Errorf("wrong value for colliding key. got=%v", pair.Value)
+	}
+
+	hash.Delete(a)
+	if _, ok := hash.Get(a); ok {
+		t.Errorf("deleted colliding key was found")
+	}
+	if _, ok := hash.Get(b); !ok {
+		t.Errorf("deleting a colliding key removed the other")
+	}
+}
+
+func TestFloatHashKeys(t *testing.T) {
+	tests := []struct {
+		left     Hashable
+		right    Hashable
+		expected bool
+	}{
+		{&Float{Value: 0.0000001}, &Float{Value: 0}, false},
+		{&Float{Value: 2.5}, &Float{Value: 2.5}, true},
+		{&Float{Value: math.Copysign(0, -1)}, &Float{Value: 0}, true},
+		{&Float{Value: math.NaN()}, &Float{Value: math.NaN()}, true},
+		{&Float{Value: 1}, &Integer{Value: 1}, false},
+	}
+
+	for _, tt := range tests {
+		hash := NewHash()
+		hash.Set(tt.left, TRUE)
+		_, found := hash.Get(tt.right)
+
+		if found != tt.expected {
+			t.Errorf("looking up %s in a hash keyed by %s wrong. want=%t, got=%t",
+				tt.right.Inspect(), tt.left.Inspect(), tt.expected, found)
+		}
+	}
+}
+
+func TestStringRunes(t *testing.T) {
+	str := &String{Value: "héllo"}
+
+	if str.Len() != 5 {
+		t.Errorf("string has wrong length. want=5, got=%d", str.Len())
+	}
+	if string(str.Runes()[1]) != "é" {
+		t.Errorf("wrong code point at 1. got=%q", string(str.Runes()[1]))
+	}
+}
+
+func TestArgsAndExit(t *testing.T) {
+	defer func(arguments []string) {
+		Arguments = arguments
+	}(Arguments)
+
+	Arguments = []string{"a", "b"}
+
+	result := GetBuiltinByName("args").Function()
+	array, ok := result.(*Array)
+	if !ok || len(array.Elements) != 2 || array.Elements[1].Inspect() != "b" {
+		t.Errorf("wrong arguments. got=%s", result.Inspect())
+	}
+
+	result = GetBuiltinByName("exit").Function(&Integer{Value: 3})
+	exit, ok := result.(*Exit)
+	if !ok || exit.Status != 3 {
+		t.Errorf("wrong exit. want=exit(3), got=%s", result.Inspect())
+	}
+
+	result = GetBuiltinByName("exit").Function(&String{Value: "3"})
+	if _, ok := result.(*Error); !ok {
+		t.Errorf("expected error for non integer code. got=%s", result.Inspect())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	one := &Integer{Value: 1}
+	fn := &Builtin{}
+
+	tests := []struct {
+		left     Object
+		right    Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{NULL, &Null{}, true},
+		{fn, fn, true},
+		{fn, &Builtin{}, false},
+		{
+			&Array{Elements: []Object{one, &Array{Elements: []Object{TRUE}}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{TRUE}}}},
+			true,
+		},
+		{newTestHash(one, NULL), newTestHash(one, FALSE), false},
+		{newTestHash(one, NULL, TRUE, FALSE), newTestHash(TRUE, FALSE, one, NULL), true},
+	}
+
+	for _, tt := range tests {
+		if Equal(tt.left, tt.right) != tt.expected {
+			t.Errorf("Equal(%s, %s) wrong. want=%t", tt.left.Inspect(), tt.right.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestEqualCycles(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	a.Elements[1] = a
+	b := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	b.Elements[1] = b
+
+	if !Equal(a, b) {
+		t.Errorf("arrays that contain themselves the same way should be equal")
+	}
+
+	b.Elements[0] = &Integer{Value: 2}
+	if Equal(a, b) {
+		t.Errorf("arrays with different elements should not be equal")
+	}
+}
+
+// builds a hash from alternating keys and values
+func newTestHash(pairs ...Object) *Hash {
+	hash := NewHash()
+	for i := 0; i < len(pairs); i += 2 {
+		hash.Set(pairs[i].(Hashable), pairs[i + 1])
+	}
+	return hash
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	for i := 0; i < 20; i++ {
+		hash.Set(&Integer{Value: int64(i)}, &Integer{Value: int64(i)})
+	}
+
+	// deleting most keys compacts the pairs, which must keep their order
+	for i := 0; i < 20; i++ {
+		if i % 5 != 0 && !hash.Delete(&Integer{Value: int64(i)}) {
+			t.Errorf("key %d was not deleted", i)
+		}
+	}
+	if hash.Delete(&Integer{Value: 1}) {
+		t.Errorf("deleting a missing key reported a delete")
+	}
+
+	// setting an existing key keeps its place, a new key goes last
+	hash.Set(&Integer{Value: 5}, TRUE)
+	hash.Set(&Integer{Value: 1}, FALSE)
+
+	if hash.Len() != 5 {
+		t.Errorf("hash has wrong length. want=5, got=%d", hash.Len())
+	}
+
+	expected := "{0: 0, 5: true, 10: 10, 15: 15, 1: false}"
+	if hash.Inspect() != expected {
+		t.Errorf("hash has wrong order. want=%s, got=%s", expected, hash.Inspect())
+	}
+
+	pair, ok := hash.Get(&Integer{Value: 15})
+	if !ok || pair.Value.Inspect() != "15" {
+		t.Errorf("lookup after compacting failed. got=%v", pair.Value)
+	}
+	if _, ok := hash.Get(&Integer{Value: 2}); ok {
+		t.Errorf("deleted key was found")
+	}
+}
@@ -0,0 +1,170 @@
+package object
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// groups of builtins that reach outside of the script. A sandbox grants
+// each group separately, anything not granted is denied
+type Capability string
+
+const (
+	FILESYSTEM_CAP Capability = "fs"
+	EXEC_CAP       Capability = "exec"
+	ENV_CAP        Capability = "env"
+	EXIT_CAP       Capability = "exit"
+)
+
+// policy for running untrusted scripts. Paths holds the glob patterns
+// (as understood by filepath.Match) of the files that can be opened or
+// removed, an empty list denies the filesystem entirely
+type Sandbox struct {
+	Exec bool
+	Env bool
+	Exit bool
+	Paths []string
+}
+
+// the policy in use by the builtins of both engines. nil means the
+// script runs unrestricted
+var sandbox *Sandbox
+
+func SetSandbox(s *Sandbox) {
+	sandbox = s
+}
+
+func GetSandbox() *Sandbox {
+	return sandbox
+}
+
+// builds a sandbox from a comma separated list of grants. "exec" allows
+// running commands, "env" allows reading the process arguments, "exit"
+// allows ending the program with a status, and "fs=<glob>" allows access
+// to the matching paths. "none" grants nothing.
+// ie "fs=data/*.txt,env"
+func ParseSandbox(spec string) (*Sandbox, error) {
+	s := &Sandbox{Paths: []string{}}
+
+	for _, grant := range strings.Split(spec, ",") {
+		grant = strings.TrimSpace(grant)
+
+		switch {
+		case grant == "" || grant == "none":
+			continue
+		case grant == string(EXEC_CAP):
+			s.Exec = true
+		case grant == string(ENV_CAP):
+			s.Env = true
+		case grant == string(EXIT_CAP):
+			s.Exit = true
+		case strings.HasPrefix(grant, string(FILESYSTEM_CAP) + "="):
+			pattern := strings.TrimPrefix(grant, string(FILESYSTEM_CAP) + "=")
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("bad sandbox path pattern %q: %s",
+					pattern, err)
+			}
+			s.Paths = append(s.Paths, pattern)
+		default:
+			return nil, fmt.Errorf("unknown sandbox grant %q", grant)
+		}
+	}
+
+	return s, nil
+}
+
+// returns an error object naming the capability if the sandbox does
+// not grant it to the given builtin, otherwise returns nil
+func checkCapability(capability Capability, builtin string) *Error {
+	if sandbox == nil {
+		return nil
+	}
+
+	var allowed bool
+	switch capability {
+	case EXEC_CAP:
+		allowed = sandbox.Exec
+	case ENV_CAP:
+		allowed = sandbox.Env
+	case EXIT_CAP:
+		allowed = sandbox.Exit
+	case FILESYSTEM_CAP:
+		allowed = len(sandbox.Paths) > 0
+	}
+
+	if !allowed {
+		return newError("sandbox denied `%s`: capability %q is not granted",
+			builtin, capability)
+	}
+
+	return nil
+}
+
+// checks that the path matches one of the patterns of the sandbox. Both
+// the path and the patterns are resolved first, see resolvePath, so that
+// "./a.txt" and "a.txt" are treated as the same file and a symlink in an
+// allowed directory cannot point outside of it
+func checkPath(path string, builtin string) *Error {
+	if sandbox == nil {
+		return nil
+	}
+
+	if err := checkCapability(FILESYSTEM_CAP, builtin); err != nil {
+		return err
+	}
+
+	resolvedPath, err := resolvePath(path)
+	if err != nil {
+		return newError("sandbox denied `%s`: %s", builtin, err.Error())
+	}
+
+	for _, pattern := range sandbox.Paths {
+		resolvedPattern, err := resolvePath(pattern)
+		if err != nil {
+			continue
+		}
+
+		if ok, _ := filepath.Match(resolvedPattern, resolvedPath); ok {
+			return nil
+		}
+	}
+
+	return newError("sandbox denied `%s`: capability %q is not granted for %q",
+		builtin, FILESYSTEM_CAP, path)
+}
+
+// makes the path absolute and follows the symlinks in it. Only the part
+// of the path that exists is resolved, the rest is kept as it is, so
+// files that are about to be created can be checked, and so can patterns
+// whose glob parts are not real names. A symlink whose target does not
+// exist is an error, since writing to it would create the target
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing := absPath
+	var rest string = ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if _, err := os.Lstat(existing); err == nil {
+			return "", fmt.Errorf("%q is a broken symlink", existing)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return absPath, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
@@ -0,0 +1,158 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSandbox(t *testing.T) {
+	s, err := ParseSandbox("exec, fs=data/*.txt,fs=/tmp/*,exit")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !s.Exec || s.Env || !s.Exit {
+		t.Errorf("wrong capabilities. exec=%t, env=%t, exit=%t", s.Exec, s.Env, s.Exit)
+	}
+
+	if len(s.Paths) != 2 || s.Paths[0] != "data/*.txt" || s.Paths[1] != "/tmp/*" {
+		t.Errorf("wrong paths. got=%v", s.Paths)
+	}
+
+	s, err = ParseSandbox("none")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.Exec || s.Env || s.Exit || len(s.Paths) != 0 {
+		t.Errorf("none should grant nothing. got=%+v", s)
+	}
+
+	_, err = ParseSandbox("network")
+	if err == nil {
+		t.Errorf("expected error for unknown grant")
+	}
+}
+
+func TestSandboxDeniesBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed.txt")
+	denied := filepath.Join(os.TempDir(), "denied.txt")
+
+	SetSandbox(&Sandbox{Paths: []string{filepath.Join(dir, "*.txt")}})
+	defer SetSandbox(nil)
+
+	tests := []struct {
+		builtin  string
+		args     []Object
+		expected string
+	}{
+		{
+			"command",
+			[]Object{&String{Value: "ls"}},
+			"sandbox denied `command`: capability \"exec\" is not granted",
+		},
+		{
+			"args",
+			[]Object{},
+			"sandbox denied `args`: capability \"env\" is not granted",
+		},
+		{
+			"exit",
+			[]Object{&Integer{Value: 1}},
+			"sandbox denied `exit`: capability \"exit\" is not granted",
+		},
+		{
+			"open",
+			[]Object{&String{Value: denied}},
+			"sandbox denied `open`: capability \"fs\" is not granted for \"" +
+				denied + "\"",
+		},
+		{
+			"remove",
+			[]Object{&File{Path: denied}},
+			"sandbox denied `remove`: capability \"fs\" is not granted for \"" +
+				denied + "\"",
+		},
+	}
+
+	for _, tt := range tests {
+		result := GetBuiltinByName(tt.builtin).Function(tt.args...)
+
+		errObj, ok := result.(*Error)
+		if !ok {
+			t.Errorf("%s: expected Error. got=%T (%+v)", tt.builtin, result, result)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expected, errObj.Message)
+		}
+	}
+
+	result := GetBuiltinByName("open").Function(&String{Value: allowed}, &String{Value: "w"})
+	file, ok := result.(*File)
+	if !ok {
+		t.Fatalf("open of allowed path failed. got=%T (%+v)", result, result)
+	}
+	file.Handle.Close()
+}
+
+func TestSandboxResolvesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	allowedDir := filepath.Join(dir, "allowed")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{allowedDir, outside} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("could not create %s: %s", d, err)
+		}
+	}
+
+	secret := filepath.Join(outside, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatalf("could not write %s: %s", secret, err)
+	}
+
+	escape := filepath.Join(allowedDir, "escape.txt")
+	dangling := filepath.Join(allowedDir, "dangling.txt")
+	linkedDir := filepath.Join(allowedDir, "linked")
+	links := map[string]string{
+		escape: secret,
+		dangling: filepath.Join(outside, "missing.txt"),
+		linkedDir: outside,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %s", err)
+		}
+	}
+
+	SetSandbox(&Sandbox{Paths: []string{
+		filepath.Join(allowedDir, "*"),
+		filepath.Join(allowedDir, "*", "*"),
+	}})
+	defer SetSandbox(nil)
+
+	open := GetBuiltinByName("open").Function
+
+	for _, path := range []string{escape, dangling, filepath.Join(linkedDir, "new.txt")} {
+		result := open(&String{Value: path}, &String{Value: "w"})
+		if _, ok := result.(*Error); !ok {
+			t.Errorf("open of %s should be denied. got=%T (%+v)", path, result, result)
+		}
+	}
+
+	content, err := os.ReadFile(secret)
+	if err != nil || string(content) != "secret" {
+		t.Errorf("file outside the sandbox was changed. got=%q, %v", content, err)
+	}
+
+	// files that do not exist yet can still be created in the directory
+	result := open(&String{Value: filepath.Join(allowedDir, "new.txt")}, &String{Value: "w"})
+	file, ok := result.(*File)
+	if !ok {
+		t.Fatalf("open of new allowed file failed. got=%T (%+v)", result, result)
+	}
+	file.Handle.Close()
+}
@@ -0,0 +1,116 @@
+package object
+
+import "fmt"
+
+// takes the elements of an array, or the characters of a string, from
+// start up to but not including end, step apart. The bounds are NULL when
+// they were left out, and then cover the whole value, from the end
+// backwards when step is negative. Negative bounds count from the end,
+// and bounds past either end are moved to it, so slicing never fails
+// because of its bounds
+func Slice(left, start, end, step Object) (Object, error) {
+	var length int
+	switch left := left.(type) {
+	case *Array:
+		length = len(left.Elements)
+	case *String:
+		length = left.Len()
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+
+	indexes, err := sliceIndexes(length, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+
+	if array, ok := left.(*Array); ok {
+		elements := make([]Object, len(indexes))
+		for i, index := range indexes {
+			elements[i] = array.Elements[index]
+		}
+		return &Array{Elements: elements}, nil
+	}
+
+	runes := left.(*String).Runes()
+	chars := make([]rune, len(indexes))
+	for i, index := range indexes {
+		chars[i] = runes[index]
+	}
+	return &String{Value: string(chars)}, nil
+}
+
+// the indexes a slice of a value of the given length takes, in order
+func sliceIndexes(length int, start, end, step Object) ([]int, error) {
+	var by int = 1
+	if step != NULL {
+		value, ok := step.(*Integer)
+		if !ok {
+			return nil, fmt.Errorf("slice step must be INTEGER, got %s", step.Type())
+		}
+		if value.Value == 0 {
+			return nil, fmt.Errorf("slice step cannot be zero")
+		}
+		by = int(value.Value)
+	}
+
+	// going backwards the slice starts at the last element, and ends
+	// before the first, which is -1
+	var from, to int = 0, length
+	if by < 0 {
+		from, to = length - 1, -1
+	}
+
+	if start != NULL {
+		value, ok := start.(*Integer)
+		if !ok {
+			return nil, fmt.Errorf("slice start must be INTEGER, got %s", start.Type())
+		}
+		from = clampBound(value.Value, length, by)
+	}
+	if end != NULL {
+		value, ok := end.(*Integer)
+		if !ok {
+			return nil, fmt.Errorf("slice end must be INTEGER, got %s", end.Type())
+		}
+		to = clampBound(value.Value, length, by)
+	}
+
+	// counting the indexes first keeps a huge step from overflowing
+	// past the end
+	var count int = 0
+	if by > 0 && from < to {
+		count = (to - from - 1) / by + 1
+	}
+	if by < 0 && from > to {
+		count = (from - to - 1) / -by + 1
+	}
+
+	indexes := make([]int, count)
+	for i := range indexes {
+		indexes[i] = from + i * by
+	}
+	return indexes, nil
+}
+
+// turns a bound into an index, counting negative bounds from the end and
+// moving bounds past either end to just outside it
+func clampBound(bound int64, length int, step int) int {
+	if bound < 0 {
+		bound += int64(length)
+	}
+
+	if bound < 0 {
+		if step < 0 {
+			return -1
+		}
+		return 0
+	}
+	if bound >= int64(length) {
+		if step < 0 {
+			return length - 1
+		}
+		return length
+	}
+	return int(bound)
+}
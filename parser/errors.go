@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"mylang/token"
+)
+
+// a syntax error with the position of the token it was found at. Expected
+// is set when a specific token was missing, and Suggestion holds a likely
+// fix when there is one
+type ParseError struct {
+	Line int
+	Column int
+	Expected token.TokenType
+	Found token.Token
+	Message string
+	Suggestion string
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s, at line %d, column %d", e.Message, e.Line, e.Column)
+
+	if e.Suggestion != "" {
+		msg += ": " + e.Suggestion
+	}
+
+	return msg
+}
+
+// tokens that start a statement, where the parser can pick up again
+// after an error
+var statementStarts = map[token.TokenType]bool{
+	token.LET:    true,
+	token.RETURN: true,
+	token.IF:     true,
+	token.WHILE:  true,
+	token.SWITCH: true,
+}
+
+// records an error at the given token. Once the parser has recorded an
+// error it is panicking, and any error found before it synchronizes is
+// dropped since it is most likely caused by the first one
+func (p *Parser) addError(tok token.Token, expected token.TokenType,
+	suggestion string, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	p.errors = append(p.errors, &ParseError{
+		Line: tok.Line,
+		Column: tok.Column,
+		Expected: expected,
+		Found: tok,
+		Message: fmt.Sprintf(format, a...),
+		Suggestion: suggestion,
+	})
+}
+
+// adds the problems the lexer found, like unterminated strings, to the
+// errors and puts all of them in the order of the source
+func (p *Parser) addLexerErrors() {
+	for _, err := range p.l.Errors() {
+		p.errors = append(p.errors, &ParseError{
+			Line: err.Line,
+			Column: err.Column,
+			Message: err.Message,
+		})
+	}
+
+	sort.SliceStable(p.errors, func(i, j int) bool {
+		if p.errors[i].Line != p.errors[j].Line {
+			return p.errors[i].Line < p.errors[j].Line
+		}
+		return p.errors[i].Column < p.errors[j].Column
+	})
+}
+
+// skips tokens until the end of the broken statement. Stops on a
+// semicolon, before the closing brace of the enclosing block, before a
+// keyword that starts a statement, or after the brace that closes a
+// block opened within the broken statement. Then stops panicking
+func (p *Parser) synchronize() {
+	depth := 0
+
+	for p.currentToken.Type != token.EOF && p.nextToken.Type != token.EOF {
+		switch p.currentToken.Type {
+		case token.OBRACE:
+			depth++
+		case token.CBRACE:
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					p.panicking = false
+					return
+				}
+			}
+		}
+
+		if depth == 0 {
+			if p.currentToken.Type == token.SCOLON ||
+				p.nextToken.Type == token.CBRACE ||
+				statementStarts[p.nextToken.Type] {
+				break
+			}
+		}
+
+		p.advanceTokens()
+	}
+
+	p.panicking = false
+}
+
+// guesses a fix for a missing token
+func suggestFor(expected token.TokenType, found token.Token) string {
+	switch expected {
+	case token.IDENT:
+		if token.LookupIdent(found.Literal) != token.IDENT {
+			return fmt.Sprintf("%q is a keyword and cannot be used as a name", found.Literal)
+		}
+		return "add a name here"
+	case token.ASSIGN:
+		return "add '=' followed by a value"
+	case token.CPAREN, token.CBRACKET, token.CBRACE:
+		return fmt.Sprintf("add the missing %q", string(expected))
+	case token.OBRACE:
+		return "add '{' to start the block"
+	}
+	return fmt.Sprintf("add %q", string(expected))
+}
+
+// guesses a fix for a token that cannot start an expression
+func suggestForUnexpected(found token.Token) string {
+	switch found.Type {
+	case token.CPAREN, token.CBRACKET, token.CBRACE:
+		return fmt.Sprintf("remove the unmatched %q", found.Literal)
+	case token.SCOLON:
+		return "an expression is missing before ';'"
+	case token.EOF:
+		return "the input ended before the expression was complete"
+	}
+	return ""
+}
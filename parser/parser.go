@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"mylang/ast"
 	"mylang/lexer"
 	"mylang/token"
@@ -10,13 +9,16 @@ import (
 
 type Parser struct {
 	l *lexer.Lexer
-	errors []string  // for holding multiple erros instead of halting on every error
+	errors []*ParseError  // for holding multiple erros instead of halting on every error
+	panicking bool  // set after an error until the parser synchronizes
 	// current and next token for identifying different kinds of expressions and statements
 	currentToken token.Token
 	nextToken token.Token
 	// map of prefix and infix functions that hold the specific function for every expression
 	prefixParseFunctions map[token.TokenType]prefixParseFunction
 	infixParseFunctions map[token.TokenType]infixParseFunction
+	// comments returned by the lexer, which are kept out of the tokens
+	comments []*ast.Comment
 }
 
 // types of fucntions for the parser with prefixParseFunction being
@@ -59,11 +61,20 @@ var precedences = map[token.TokenType]int{
 	token.OBRACKET: INDEX,
 }
 
+// returns the precedence of a binary operator, or LOWEST for tokens
+// that are not operators
+func Precedence(t token.TokenType) int {
+	if precedence, ok := precedences[t]; ok {
+		return precedence
+	}
+	return LOWEST
+}
+
 // makes and returns a parser for the given lexer
 func New(l *lexer.Lexer) *Parser {
 	var p *Parser = &Parser{
 		l: l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 
 	p.prefixParseFunctions = make(map[token.TokenType]prefixParseFunction)
@@ -71,6 +82,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFunctions[token.INT]      = p.parseIntegerLiteral
 	p.prefixParseFunctions[token.FLOAT]    = p.parseFloatLiteral
 	p.prefixParseFunctions[token.STRING]   = p.parseStringLiteral
+	p.prefixParseFunctions[token.TEMPLATE] = p.parseInterpolatedString
 	p.prefixParseFunctions[token.TRUE]     = p.parseBooleanLiteral
 	p.prefixParseFunctions[token.FALSE]    = p.parseBooleanLiteral
 	p.prefixParseFunctions[token.BANG]     = p.parsePrefixExpression
@@ -111,13 +123,12 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.currentToken.Type != token.EOF {
-		var statement ast.Statement = p.parseStatement()
-		if statement != nil {
-			program.Statements = append(program.Statements, statement)
-		}
+		program.Statements = append(program.Statements, p.parseStatementOrRecover())
 		p.advanceTokens()
 	}
 
+	program.Comments = p.comments
+	p.addLexerErrors()
 	return program
 }
 
@@ -126,6 +137,49 @@ func (p *Parser) ParseProgram() *ast.Program {
 func (p *Parser) advanceTokens() {
 	p.currentToken = p.nextToken
 	p.nextToken = p.l.NextToken()
+
+	for p.nextToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{
+			Token: p.nextToken,
+			Trailing: p.currentToken.Line == p.nextToken.Line,
+		})
+		p.nextToken = p.l.NextToken()
+	}
+}
+
+// parses the next statement. If the statement has an error the parser
+// skips to the end of it, and a statement that could not be parsed at all
+// is replaced with a BadStatement so the program keeps its shape
+func (p *Parser) parseStatementOrRecover() ast.Statement {
+	start := p.currentToken
+	statement := p.parseStatement()
+
+	if !p.panicking {
+		return statement
+	}
+
+	p.synchronize()
+
+	switch s := statement.(type) {
+	case *ast.LetStatement:
+		if s != nil {
+			return s
+		}
+	case *ast.ReturnStatement:
+		if s != nil {
+			return s
+		}
+	case *ast.AssignmentStatement:
+		if s != nil {
+			return s
+		}
+	case *ast.ExpressionStatement:
+		if s != nil {
+			return s
+		}
+	}
+
+	return &ast.BadStatement{Token: start}
 }
 
 // used by the ParseProgram method to parse the next statement in the program
@@ -247,9 +301,8 @@ func (p *Parser) expectedToken(t token.TokenType) bool {
 }
 
 func (p *Parser) expectedTokenError(t token.TokenType) {
-	var msg string = fmt.Sprintf("expected next token to be %s, got %s instead, at line %d",
-		t, p.nextToken.Type, p.l.GetLine())
-	p.errors = append(p.errors, msg)
+	p.addError(p.nextToken, t, suggestFor(t, p.nextToken),
+		"expected next token to be %s, got %s instead", t, p.nextToken.Type)
 }
 
 // recursive function that constructs the ordering of expressions and operations based
@@ -267,11 +320,15 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFunctions[p.currentToken.Type]
 
 	if prefix == nil {
-		p.noPrefixParseFunctionError(p.currentToken.Type)
-		return nil
+		p.noPrefixParseFunctionError(p.currentToken)
+		return &ast.BadExpression{Token: p.currentToken}
 	}
 
+	start := p.currentToken
 	leftExpression := prefix()
+	if leftExpression == nil {
+		return &ast.BadExpression{Token: start}
+	}
 
 	for p.nextToken.Type != token.SCOLON && precedence < p.nextPrecedence() {
 		infix := p.infixParseFunctions[p.nextToken.Type]
@@ -283,15 +340,17 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.advanceTokens()
 
 		leftExpression = infix(leftExpression)
+		if leftExpression == nil {
+			return &ast.BadExpression{Token: start}
+		}
 	}
 
 	return leftExpression
 }
 
-func (p *Parser) noPrefixParseFunctionError(t token.TokenType) {
-	var msg string = fmt.Sprintf("no prefix parse function for %s found, at line %d",
-		t, p.l.GetLine())
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseFunctionError(tok token.Token) {
+	p.addError(tok, "", suggestForUnexpected(tok),
+		"no prefix parse function for %s found", tok.Type)
 }
 
 // returns the precedence of the next token
@@ -315,9 +374,8 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 	if err != nil {
-		var msg string = fmt.Sprintf("could not parse %q as integer, at line %d",
-			p.currentToken.Literal, p.l.GetLine())
-		p.errors = append(p.errors, msg)
+		p.addError(p.currentToken, "", "",
+			"could not parse %q as integer", p.currentToken.Literal)
 		return nil
 	}
 
@@ -331,9 +389,8 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 
 	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
 	if err != nil {
-		var msg string = fmt.Sprintf("could not parse %q as float, at line %d",
-			p.currentToken.Literal, p.l.GetLine())
-		p.errors = append(p.errors, msg)
+		p.addError(p.currentToken, "", "",
+			"could not parse %q as float", p.currentToken.Literal)
 		return nil
 	}
 
@@ -354,6 +411,36 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
 }
 
+// prefix parse function for the TEMPLATE token that starts an interpolated
+// string. The lexer splits the string into TEMPLATE tokens for the text
+// before each ${, the tokens of the expressions, and a STRING token for
+// the text after the last }
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	interpolated := &ast.InterpolatedString{Token: p.currentToken, Parts: []ast.Expression{}}
+
+	for {
+		if p.currentToken.Literal != "" {
+			interpolated.Parts = append(interpolated.Parts, p.parseStringLiteral())
+		}
+
+		p.advanceTokens()
+		interpolated.Parts = append(interpolated.Parts, p.parseExpression(LOWEST))
+
+		if p.nextToken.Type == token.TEMPLATE {
+			p.advanceTokens()
+			continue
+		}
+		if !p.expectedToken(token.STRING) {
+			return nil
+		}
+
+		if p.currentToken.Literal != "" {
+			interpolated.Parts = append(interpolated.Parts, p.parseStringLiteral())
+		}
+		return interpolated
+	}
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -453,12 +540,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.advanceTokens()
 
 	for p.currentToken.Type != token.CBRACE && p.currentToken.Type != token.EOF {
-		statement := p.parseStatement()
-		if statement != nil {
-			block.Statements = append(block.Statements, statement)
-		}
+		block.Statements = append(block.Statements, p.parseStatementOrRecover())
 		p.advanceTokens()
 	}
+
+	block.Close = p.currentToken
 	return block
 }
 
@@ -556,9 +642,9 @@ func (p *Parser) parseSwitchExpression() ast.Expression {
 			p.advanceTokens()
 			caseExpr.Value = p.parseExpression(LOWEST)
 		default:
-			p.errors = append(p.errors,
-				fmt.Sprintf("expected case or default, at line %d, got %s",
-					p.l.GetLine(), p.currentToken.Type))
+			p.addError(p.currentToken, token.CASE,
+				"start each branch with 'case <value>' or 'default'",
+				"expected case or default, got %s", p.currentToken.Type)
 			return nil
 		}
 
@@ -633,10 +719,51 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	literal.Body = p.parseBlockStatement()
+	markTailCalls(literal.Body, true)
 
 	return literal
 }
 
+// marks the calls in a function body whose value is returned directly by
+// the function. Those are the calls in return statements and, when the
+// block is in tail position, the call that is the last expression of the
+// block, including the last expressions of if and switch branches. Nested
+// function literals are not walked as they mark their own bodies
+func markTailCalls(block *ast.BlockStatement, tail bool) {
+	if block == nil {
+		return
+	}
+
+	for i, statement := range block.Statements {
+		last := i == len(block.Statements) - 1
+
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			markTailExpression(statement.ReturnValue, true)
+		case *ast.ExpressionStatement:
+			markTailExpression(statement.Expression, tail && last)
+		}
+	}
+}
+
+// marks the expression as a tail call if it is a call in tail position,
+// and looks for return statements in the blocks of the expression
+func markTailExpression(expression ast.Expression, tail bool) {
+	switch expression := expression.(type) {
+	case *ast.CallExpression:
+		expression.Tail = tail
+	case *ast.IfExpression:
+		markTailCalls(expression.Consequence, tail)
+		markTailCalls(expression.Alternative, tail)
+	case *ast.SwitchExpression:
+		for _, c := range expression.Cases {
+			markTailCalls(c.Body, tail)
+		}
+	case *ast.WhileExpression:
+		markTailCalls(expression.Body, false)
+	}
+}
+
 // creates a call expression node and parses the call arguments
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{
@@ -650,25 +777,76 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	expression := &ast.IndexExpression{Token: p.currentToken, Left: left}
+	tok := p.currentToken
+
+	if p.nextToken.Type == token.COLON {
+		p.advanceTokens()
+		return p.parseSliceExpression(tok, left, nil)
+	}
 
 	p.advanceTokens()
-	expression.Index = p.parseExpression(LOWEST)
+	index := p.parseExpression(LOWEST)
+
+	if p.nextToken.Type == token.COLON {
+		p.advanceTokens()
+		return p.parseSliceExpression(tok, left, index)
+	}
 
 	if !p.expectedToken(token.CBRACKET) {
 		return nil
 	}
 
-	return expression
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
 }
 
+// parses the rest of a slice from the colon after its start, which is nil
+// when it was left out, up to the closing bracket
+func (p *Parser) parseSliceExpression(
+	tok token.Token,
+	left ast.Expression,
+	start ast.Expression,
+) ast.Expression {
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	if p.nextToken.Type != token.COLON && p.nextToken.Type != token.CBRACKET {
+		p.advanceTokens()
+		slice.End = p.parseExpression(LOWEST)
+	}
+
+	if p.nextToken.Type == token.COLON {
+		p.advanceTokens()
+		if p.nextToken.Type != token.CBRACKET {
+			p.advanceTokens()
+			slice.Step = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectedToken(token.CBRACKET) {
+		return nil
+	}
+
+	return slice
+}
+
+// returns the messages of the syntax errors found while parsing
 func (p *Parser) Errors() []string {
+	messages := []string{}
+	for _, err := range p.errors {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// returns the syntax errors found while parsing along with their
+// positions and suggested fixes
+func (p *Parser) ParseErrors() []*ParseError {
 	return p.errors
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.currentToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Keys = []ast.Expression{}
 
 	for p.nextToken.Type != token.CBRACE {
 		p.advanceTokens()
@@ -682,6 +860,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if p.nextToken.Type != token.CBRACE && !p.expectedToken(token.COMMA) {
 			return nil
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"mylang/ast"
 	"mylang/lexer"
+	"mylang/token"
 	"testing"
 )
 
@@ -153,6 +154,34 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	var input string = `"sum ${a + 1}: ${f("${b}")}!"`
+
+	l := lexer.New(input)
+	var p *Parser = New(l)
+	var program *ast.Program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	interpolated, ok := statement.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", statement.Expression)
+	}
+
+	expected := []string{"sum ", "(a + 1)", ": ", "f(${b})", "!"}
+	if len(interpolated.Parts) != len(expected) {
+		t.Fatalf("wrong number of parts. want=%d, got=%d", len(expected), len(interpolated.Parts))
+	}
+	for i, part := range interpolated.Parts {
+		if part.String() != expected[i] {
+			t.Errorf("parts[%d] wrong. want=%q, got=%q", i, expected[i], part.String())
+		}
+	}
+	if _, ok := interpolated.Parts[0].(*ast.StringLiteral); !ok {
+		t.Errorf("parts[0] not *ast.StringLiteral. got=%T", interpolated.Parts[0])
+	}
+}
+
 func TestFloatLiteralExpression(t *testing.T) {
 	var input string = `0.3333;`
 
@@ -718,6 +747,33 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[1::-1]", "(a[1::(-1)])"},
+		{"a[-2:b + 1:c]", "(a[(-2):(b + 1):c])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		var p *Parser = New(l)
+		var program *ast.Program = p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	var input string = `{"one": 1, "two": 2, "three": 3}`
 
@@ -752,6 +808,12 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		expectedValue := expected[literal.String()]
 		testIntegerLiteral(t, value, expectedValue)
 	}
+
+	for i, name := range []string{"one", "two", "three"} {
+		if hash.Keys[i].String() != name {
+			t.Errorf("hash.Keys[%d] wrong. want=%s, got=%s", i, name, hash.Keys[i].String())
+		}
+	}
 }
 
 func TestParsingEmptyHashLiteral(t *testing.T) {
@@ -1004,6 +1066,179 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 }
 
+func TestTailCallMarking(t *testing.T) {
+	input := `
+	let f = func(x) {
+		g(x);
+		while (x) { return h(x); }
+		if (x) { i(x) } else { j(x) + 1 }
+	};
+	k(1);
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := map[string]bool{
+		"g": false,
+		"h": true,
+		"i": true,
+		"j": false,
+		"k": false,
+	}
+
+	calls := map[string]bool{}
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			walk(node.Value)
+		case *ast.ExpressionStatement:
+			walk(node.Expression)
+		case *ast.ReturnStatement:
+			walk(node.ReturnValue)
+		case *ast.BlockStatement:
+			for _, s := range node.Statements {
+				walk(s)
+			}
+		case *ast.FunctionLiteral:
+			walk(node.Body)
+		case *ast.WhileExpression:
+			walk(node.Body)
+		case *ast.IfExpression:
+			walk(node.Consequence)
+			walk(node.Alternative)
+		case *ast.InfixExpression:
+			walk(node.Left)
+		case *ast.CallExpression:
+			calls[node.Function.String()] = node.Tail
+		}
+	}
+
+	for _, s := range program.Statements {
+		walk(s)
+	}
+
+	for name, tail := range expected {
+		got, ok := calls[name]
+		if !ok {
+			t.Errorf("call to %s not found", name)
+			continue
+		}
+		if got != tail {
+			t.Errorf("call to %s has wrong Tail. want=%t, got=%t", name, tail, got)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let x = 5 +;
+let = 10;
+let f = func(a) {
+	let y = (a + ;
+	a * 2
+};
+if (x { puts(x) }
+let z = ) ] 3;
+puts(z);`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	expectedErrors := []struct {
+		line     int
+		column   int
+		expected string
+		found    string
+	}{
+		{1, 12, "", ";"},
+		{2, 5, "IDENT", "="},
+		{4, 15, "", ";"},
+		{7, 7, ")", "{"},
+		{8, 9, "", ")"},
+	}
+
+	errors := p.ParseErrors()
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%v)",
+			len(expectedErrors), len(errors), p.Errors())
+	}
+
+	for i, expected := range expectedErrors {
+		err := errors[i]
+		if err.Line != expected.line || err.Column != expected.column {
+			t.Errorf("errors[%d] wrong position. want=%d:%d, got=%d:%d",
+				i, expected.line, expected.column, err.Line, err.Column)
+		}
+		if string(err.Expected) != expected.expected {
+			t.Errorf("errors[%d] wrong expected token. want=%q, got=%q",
+				i, expected.expected, err.Expected)
+		}
+		if err.Found.Literal != expected.found {
+			t.Errorf("errors[%d] wrong found token. want=%q, got=%q",
+				i, expected.found, err.Found.Literal)
+		}
+	}
+
+	expectedError := `expected next token to be ), got { instead, at line 7, column 7: add the missing ")"`
+	if p.Errors()[3] != expectedError {
+		t.Errorf("wrong error message. want=%q, got=%q", expectedError, p.Errors()[3])
+	}
+
+	expectedStatements := []string{
+		"let x = (5 + <bad expression>);",
+		"<bad statement>",
+		"let f = func<f>(a) let y = <bad expression>;(a * 2);",
+		"<bad expression>",
+		"let z = <bad expression>;",
+		"puts(z)",
+	}
+
+	if len(program.Statements) != len(expectedStatements) {
+		t.Fatalf("wrong number of statements. want=%d, got=%d",
+			len(expectedStatements), len(program.Statements))
+	}
+
+	for i, expected := range expectedStatements {
+		if program.Statements[i].String() != expected {
+			t.Errorf("statements[%d] wrong. want=%q, got=%q",
+				i, expected, program.Statements[i].String())
+		}
+	}
+}
+
+func TestErrorSuggestions(t *testing.T) {
+	tests := []struct {
+		input      string
+		suggestion string
+	}{
+		{"let if = 1;", `"if" is a keyword and cannot be used as a name`},
+		{"let x 1;", "add '=' followed by a value"},
+		{"[1, 2", `add the missing "]"`},
+		{"1 + ", "the input ended before the expression was complete"},
+		{"switch (x) { 1 { 2 } }", "start each branch with 'case <value>' or 'default'"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("no errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0].Suggestion != tt.suggestion {
+			t.Errorf("wrong suggestion for %q. want=%q, got=%q",
+				tt.input, tt.suggestion, errors[0].Suggestion)
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	var errors []string = p.Errors()
 	
@@ -1156,3 +1391,64 @@ func testInfixExpression(
 	}
 	return true
 }
+
+func TestComments(t *testing.T) {
+	p := New(lexer.NewWithComments("// leading\nlet x = 1; // trailing\nif (x) {\n\t/* inside */\n}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("comments should not become statements. got=%d statements",
+			len(program.Statements))
+	}
+
+	expected := []struct {
+		literal  string
+		trailing bool
+	}{
+		{"// leading", false},
+		{"// trailing", true},
+		{"/* inside */", false},
+	}
+
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("wrong number of comments. want=%d, got=%d",
+			len(expected), len(program.Comments))
+	}
+
+	for i, e := range expected {
+		comment := program.Comments[i]
+		if comment.Token.Literal != e.literal || comment.Trailing != e.trailing {
+			t.Errorf("wrong comment. want=%q trailing=%t, got=%q trailing=%t",
+				e.literal, e.trailing, comment.Token.Literal, comment.Trailing)
+		}
+	}
+
+	block := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Consequence
+	if block.Close.Type != token.CBRACE || block.Close.Line != 5 {
+		t.Errorf("wrong closing brace. got=%+v", block.Close)
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	var input string = "let a = \"\\q\";\nlet = 1;\nlet b = \"open"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"invalid escape \\q in string, at line 1, column 10",
+		"expected next token to be IDENT, got = instead, at line 2, column 5: add a name here",
+		"unterminated string, at line 3, column 9",
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%v)", len(expected), len(errors), errors)
+	}
+	for i := range expected {
+		if errors[i] != expected[i] {
+			t.Errorf("errors[%d] wrong. want=%q, got=%q", i, expected[i], errors[i])
+		}
+	}
+}
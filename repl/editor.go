@@ -0,0 +1,336 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// the most lines kept in the history file
+const MAX_HISTORY = 1000
+
+// reads a line of input after showing the prompt. Returns false once
+// the input is finished
+type lineReader interface {
+	readLine(prompt string) (string, bool)
+}
+
+// reads plain lines, used when the input is not a terminal
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out io.Writer
+}
+
+func (r *scannerReader) readLine(prompt string) (string, bool) {
+	fmt.Fprintf(r.out, prompt)
+	if !r.scanner.Scan() {
+		return "", false
+	}
+	return r.scanner.Text(), true
+}
+
+// a line editor for terminals. Supports moving the cursor with the arrow
+// keys, home and end, walking through the history with up and down, and
+// completing names with tab. The history is kept in historyFile when it
+// is set
+type lineEditor struct {
+	fd int
+	in *bufio.Reader
+	out io.Writer
+
+	history []string
+	historyFile string
+
+	// returns the words that can complete the given prefix
+	complete func(prefix string) []string
+
+	line []rune
+	cursor int
+	prompt string
+}
+
+func newLineEditor(
+	file *os.File,
+	out io.Writer,
+	historyFile string,
+	complete func(prefix string) []string,
+) *lineEditor {
+	e := &lineEditor{
+		fd: int(file.Fd()),
+		in: bufio.NewReader(file),
+		out: out,
+		history: []string{},
+		historyFile: historyFile,
+		complete: complete,
+	}
+	e.loadHistory()
+	return e
+}
+
+func (e *lineEditor) loadHistory() {
+	if e.historyFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(e.historyFile)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[len(e.history) - MAX_HISTORY:]
+	}
+}
+
+// adds the line to the history, skipping empty lines and repeats of the
+// last one, and rewrites the history file
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history) - 1] == line {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile != "" {
+		os.WriteFile(e.historyFile, []byte(strings.Join(e.history, "\n") + "\n"), 0600)
+	}
+}
+
+func (e *lineEditor) readLine(prompt string) (string, bool) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", false
+	}
+	defer restore()
+
+	return e.edit(prompt)
+}
+
+// reads keys until the line is entered, the terminal has to be in raw
+// mode already
+func (e *lineEditor) edit(prompt string) (string, bool) {
+	e.line = []rune{}
+	e.cursor = 0
+	e.prompt = prompt
+	e.refresh()
+
+	// where we are in the history, len(history) is the line being typed
+	var position int = len(e.history)
+	var typed []rune
+
+	for {
+		char, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", false
+		}
+
+		switch char {
+		case '\r', '\n':
+			io.WriteString(e.out, "\n")
+			line := string(e.line)
+			e.addHistory(line)
+			return line, true
+
+		// ctrl-d ends the input on an empty line and deletes otherwise
+		case 4:
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\n")
+				return "", false
+			}
+			e.delete()
+
+		// ctrl-c drops the line being typed
+		case 3:
+			io.WriteString(e.out, "^C\n")
+			e.line = []rune{}
+			e.cursor = 0
+			position = len(e.history)
+
+		case 127, 8:
+			if e.cursor > 0 {
+				e.cursor--
+				e.delete()
+			}
+
+		case '\t':
+			e.completeWord()
+
+		// ctrl-a and ctrl-e
+		case 1:
+			e.cursor = 0
+		case 5:
+			e.cursor = len(e.line)
+
+		// ctrl-k and ctrl-u
+		case 11:
+			e.line = e.line[:e.cursor]
+		case 21:
+			e.line = e.line[e.cursor:]
+			e.cursor = 0
+
+		case 27:
+			switch e.readEscape() {
+			case 'A':
+				if position > 0 {
+					if position == len(e.history) {
+						typed = e.line
+					}
+					position--
+					e.setLine([]rune(e.history[position]))
+				}
+			case 'B':
+				if position < len(e.history) - 1 {
+					position++
+					e.setLine([]rune(e.history[position]))
+				} else if position == len(e.history) - 1 {
+					position++
+					e.setLine(typed)
+				}
+			case 'C':
+				if e.cursor < len(e.line) {
+					e.cursor++
+				}
+			case 'D':
+				if e.cursor > 0 {
+					e.cursor--
+				}
+			case 'H':
+				e.cursor = 0
+			case 'F':
+				e.cursor = len(e.line)
+			case '~':
+				e.delete()
+			}
+
+		default:
+			if unicode.IsPrint(char) {
+				e.insert(char)
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// reads the rest of an escape sequence and returns the key it stands for,
+// 'A' to 'D' for the arrows, 'H' and 'F' for home and end, and '~' for
+// delete. Returns 0 for sequences that are not handled
+func (e *lineEditor) readEscape() rune {
+	next, _, err := e.in.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return 0
+	}
+
+	key, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if key < '0' || key > '9' {
+		return key
+	}
+
+	// sequences like "\x1b[3~", home and end can also be "\x1b[1~" and
+	// "\x1b[4~"
+	var number string = string(key)
+	for {
+		char, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if char == '~' {
+			break
+		}
+		number += string(char)
+	}
+
+	switch number {
+	case "1", "7":
+		return 'H'
+	case "4", "8":
+		return 'F'
+	case "3":
+		return '~'
+	}
+	return 0
+}
+
+func (e *lineEditor) insert(char rune) {
+	e.line = append(e.line[:e.cursor], append([]rune{char}, e.line[e.cursor:]...)...)
+	e.cursor++
+}
+
+// deletes the character under the cursor
+func (e *lineEditor) delete() {
+	if e.cursor < len(e.line) {
+		e.line = append(e.line[:e.cursor], e.line[e.cursor + 1:]...)
+	}
+}
+
+func (e *lineEditor) setLine(line []rune) {
+	e.line = append([]rune{}, line...)
+	e.cursor = len(e.line)
+}
+
+// redraws the prompt and the line, and puts the cursor back
+func (e *lineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// completes the word before the cursor. A single match is inserted,
+// several matches are completed as far as they agree and are listed
+// when there is nothing more to insert
+func (e *lineEditor) completeWord() {
+	start := e.cursor
+	for start > 0 && isWordChar(e.line[start - 1]) {
+		start--
+	}
+
+	prefix := string(e.line[start:e.cursor])
+	if prefix == "" {
+		return
+	}
+
+	matches := e.complete(prefix)
+	if len(matches) == 0 {
+		return
+	}
+
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common) - 1]
+		}
+	}
+
+	if len(common) > len(prefix) {
+		for _, char := range common[len(prefix):] {
+			e.insert(char)
+		}
+		return
+	}
+
+	if len(matches) > 1 {
+		io.WriteString(e.out, "\n" + strings.Join(matches, "  ") + "\n")
+	}
+}
+
+func isWordChar(char rune) bool {
+	return char == '_' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
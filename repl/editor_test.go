@@ -0,0 +1,171 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// an editor that reads the keys from a string instead of a terminal and
+// completes from the given words
+func newTestEditor(keys string, words ...string) *lineEditor {
+	return &lineEditor{
+		in: bufio.NewReader(strings.NewReader(keys)),
+		out: &bytes.Buffer{},
+		history: []string{},
+		complete: func(prefix string) []string {
+			matches := []string{}
+			for _, word := range words {
+				if strings.HasPrefix(word, prefix) {
+					matches = append(matches, word)
+				}
+			}
+			return matches
+		},
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	const (
+		LEFT = "\x1b[D"
+		RIGHT = "\x1b[C"
+		HOME = "\x1b[H"
+		END = "\x1b[F"
+		DELETE = "\x1b[3~"
+	)
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"ac" + LEFT + "b\r", "abc"},
+		{"bc" + HOME + "a" + END + "d\r", "abcd"},
+		{"abc\x01" + RIGHT + DELETE + "\r", "ac"},
+		{"abc\x01\x04\r", "bc"},
+		{"abcd" + LEFT + LEFT + "\x0b\r", "ab"},
+		{"abcd" + LEFT + "\x15\r", "d"},
+		{"junk\x03ok\r", "ok"},
+		{"é世\x7f\r", "é"},
+	}
+
+	for _, tt := range tests {
+		line, ok := newTestEditor(tt.keys).edit(PROMPT)
+		if !ok {
+			t.Errorf("input %q ended early", tt.keys)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. want=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+
+	// ctrl-d on an empty line ends the input
+	if _, ok := newTestEditor("\x04").edit(PROMPT); ok {
+		t.Errorf("ctrl-d on an empty line should end the input")
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	const (
+		UP = "\x1b[A"
+		DOWN = "\x1b[B"
+	)
+
+	e := newTestEditor("one\r\rtwo\rtwo\r" + UP + UP + "!\r" + "new" + UP + DOWN + "\r")
+
+	expected := []string{"one", "", "two", "two", "one!", "new"}
+	for _, want := range expected {
+		line, ok := e.edit(PROMPT)
+		if !ok || line != want {
+			t.Fatalf("wrong line. want=%q, got=%q (ok=%t)", want, line, ok)
+		}
+	}
+
+	// empty lines and repeats are not kept
+	history := []string{"one", "two", "one!", "new"}
+	if strings.Join(e.history, ",") != strings.Join(history, ",") {
+		t.Errorf("wrong history. want=%q, got=%q", history, e.history)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history")
+
+	e := newTestEditor("")
+	e.historyFile = historyFile
+	e.addHistory("let a = 1;")
+	e.addHistory("a")
+
+	data, err := os.ReadFile(historyFile)
+	if err != nil || string(data) != "let a = 1;\na\n" {
+		t.Fatalf("wrong history file. got=%q, %v", data, err)
+	}
+
+	lines := []string{}
+	for i := 0; i < MAX_HISTORY + 5; i++ {
+		lines = append(lines, strings.Repeat("x", i % 7 + 1))
+	}
+	os.WriteFile(historyFile, []byte(strings.Join(lines, "\n\n")), 0600)
+
+	e = newTestEditor("")
+	e.historyFile = historyFile
+	e.loadHistory()
+	if len(e.history) != MAX_HISTORY || e.history[0] != lines[5] {
+		t.Errorf("history not trimmed to the last %d lines. got %d lines starting %q",
+			MAX_HISTORY, len(e.history), e.history[0])
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	words := []string{"len", "let", "lines", "puts"}
+
+	tests := []struct {
+		keys     string
+		expected string
+		listed   string
+	}{
+		{"pu\t(1)\r", "puts(1)", ""},
+		{"lin\t\r", "lines", ""},
+		{"le\t\r", "le", "len  let"},
+		{"l\t\r", "l", "len  let  lines"},
+		{"x\t\r", "x", ""},
+		{"(\t\r", "(", ""},
+		{"pu + 1\x01\x1b[C\x1b[C\t\r", "puts + 1", ""},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, words...)
+		line, _ := e.edit(PROMPT)
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. want=%q, got=%q", tt.keys, tt.expected, line)
+		}
+
+		out := e.out.(*bytes.Buffer).String()
+		listed := strings.Contains(out, "\n" + tt.listed + "\n")
+		if tt.listed != "" && !listed {
+			t.Errorf("matches %q not listed for %q. got=%q", tt.listed, tt.keys, out)
+		}
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		previous := Engine
+		Engine = engine
+
+		s := newSession(&bytes.Buffer{})
+		s.run("let lettuce = 1; let total = 2;", "<repl>")
+
+		matches := strings.Join(s.complete("le"), ",")
+		if matches != "len,let,lettuce" {
+			t.Errorf("[%s] wrong matches. want=%q, got=%q", engine, "len,let,lettuce", matches)
+		}
+
+		Engine = previous
+	}
+}
@@ -5,49 +5,422 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"mylang/analyzer"
 	"mylang/ast"
+	"mylang/compiler"
+	"mylang/diagnostic"
 	"mylang/evaluator"
 	"mylang/lexer"
 	"mylang/object"
 	"mylang/parser"
+	"mylang/token"
+	"mylang/vm"
 )
 
 const PROMPT = ">> "
 
-func PrintParseErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t" + msg + "\n")
+// shown while the input so far is incomplete, like a function whose
+// closing brace has not been typed yet
+const CONTINUATION_PROMPT = ".. "
+
+// whether diagnostics are printed with ANSI colors
+var Color bool = false
+
+// the engine input is run with, "vm" or "eval", and the resource limits
+// used by the vm
+var Engine string = "vm"
+var Limits vm.Limits = vm.DefaultLimits
+
+// what the vm engine keeps from one input to the next so that globals
+// defined by earlier input can be used by later input
+type machineState struct {
+	symbolTable *compiler.SymbolTable
+	constants []object.Object
+	globals []object.Object
+}
+
+func newMachineState() *machineState {
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	return &machineState{
+		symbolTable: symbolTable,
+		constants: []object.Object{},
+		globals: []object.Object{},
+	}
+}
+
+// compiles and runs the program on the vm, and returns the value of the
+// program if it ends with an expression, otherwise nil, or the exit if
+// the program called exit. Errors are
+// given to render and also return nil. The program is compiled against a
+// copy of the symbol table, which only replaces the state once it has
+// compiled, so input that fails to compile leaves no names behind
+func (m *machineState) run(
+	program *ast.Program,
+	render func(diagnostic.Diagnostic),
+) object.Object {
+	symbolTable := m.symbolTable.Clone()
+	comp := compiler.NewWithState(symbolTable, m.constants)
+	err := comp.Compile(program)
+	if err != nil {
+		render(diagnostic.FromError(err, diagnostic.COMPILE_ERROR))
+		return nil
+	}
+
+	bytecode := comp.MakeBytecode()
+	m.symbolTable = symbolTable
+	m.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobals(bytecode, m.globals, Limits)
+	err = machine.Run()
+	m.globals = machine.Globals()
+	if exit, ok := err.(*object.Exit); ok {
+		return exit
+	}
+	if err != nil {
+		render(diagnostic.FromError(err, diagnostic.RUNTIME_ERROR,
+			"raised in " + machine.LastFrame()))
+		return nil
+	}
+
+	last := len(program.Statements) - 1
+	if last < 0 {
+		return nil
+	}
+	if _, ok := program.Statements[last].(*ast.ExpressionStatement); !ok {
+		return nil
+	}
+
+	return machine.LastPoppedStackElement()
+}
+
+// compiles the program without running it or changing the state, and
+// writes the instructions along with any functions it defines
+func (m *machineState) disassemble(
+	program *ast.Program,
+	renderer *diagnostic.Renderer,
+	out io.Writer,
+) {
+	constants := append([]object.Object{}, m.constants...)
+
+	comp := compiler.NewWithState(m.symbolTable.Clone(), constants)
+	err := comp.Compile(program)
+	if err != nil {
+		renderer.Render(out, diagnostic.FromError(err, diagnostic.COMPILE_ERROR))
+		return
+	}
+
+	bytecode := comp.MakeBytecode()
+	io.WriteString(out, bytecode.Disassemble(len(m.constants)))
+}
+
+// the state of a repl between inputs. Reset by the :reset command
+type session struct {
+	out io.Writer
+	env *object.Environment
+	machine *machineState
+	semantics *analyzer.Analyzer
+	timing bool
+	// set once input calls exit, which ends the repl
+	exited bool
+	// every input run since the last reset. Each is numbered on from the
+	// lines of the ones before it, so an error in a function defined by
+	// earlier input is shown in the input the function came from
+	inputs []input
+	nextLine int
+}
+
+// input run in the session and the line it was numbered from
+type input struct {
+	filename string
+	source string
+	firstLine int
+	lines int
+}
+
+func newSession(out io.Writer) *session {
+	s := &session{out: out}
+	s.reset()
+	return s
+}
+
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.machine = newMachineState()
+	s.semantics = analyzer.New()
+	s.inputs = []input{}
+	s.nextLine = 1
+}
+
+// parses, analyzes and runs the source with the engine set by Engine.
+// Diagnostics are rendered with the filename. Returns the value of the
+// source, or nil if it has none or failed
+func (s *session) run(source string, filename string) object.Object {
+	current := input{
+		filename: filename,
+		source: source,
+		firstLine: s.nextLine,
+		lines: strings.Count(source, "\n") + 1,
+	}
+	s.inputs = append(s.inputs, current)
+	s.nextLine += current.lines
+
+	p := parser.New(lexer.NewAtLine(source, current.firstLine))
+	var program *ast.Program = p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, d := range diagnostic.FromParseErrors(p.ParseErrors()) {
+			s.render(d)
+		}
+		return nil
+	}
+
+	for _, d := range s.semantics.Analyze(program) {
+		s.render(d)
+	}
+
+	start := time.Now()
+
+	var evaluated object.Object
+	if Engine == "eval" {
+		evaluated = evaluator.Evaluate(program, s.env)
+	} else {
+		evaluated = s.machine.run(program, s.render)
+	}
+
+	if s.timing {
+		fmt.Fprintf(s.out, "took %s\n", time.Since(start))
+	}
+
+	if _, ok := evaluated.(*object.Exit); ok {
+		s.exited = true
+		return nil
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		s.render(diagnostic.FromErrorObject(errObj))
+		return nil
+	}
+
+	return evaluated
+}
+
+// writes the diagnostic with the input its position is in, numbering
+// the lines from the start of that input. Diagnostics without a position
+// are shown with the latest input
+func (s *session) render(d diagnostic.Diagnostic) {
+	in := s.inputs[len(s.inputs) - 1]
+
+	if d.Line > 0 {
+		found := false
+		for _, candidate := range s.inputs {
+			if d.Line >= candidate.firstLine && d.Line < candidate.firstLine + candidate.lines {
+				in = candidate
+				found = true
+			}
+		}
+
+		if found {
+			d.Line -= in.firstLine - 1
+		} else {
+			d.Line = 0
+		}
+	}
+
+	diagnostic.NewRenderer(in.filename, in.source, Color).Render(s.out, d)
+}
+
+func (s *session) print(obj object.Object) {
+	if obj != nil {
+		io.WriteString(s.out, obj.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// runs a command typed after a colon, ie ":load main.ml"
+//
+//	:load file     runs the file in the session
+//	:ast expr      prints the parsed input
+//	:bytecode expr prints the compiled input without running it
+//	:type expr     runs the input and prints the type of its value
+//	:env           lists the names defined in the session
+//	:reset         forgets everything defined in the session
+//	:time          toggles printing how long input takes to run
+func (s *session) command(line string) {
+	name, argument, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	argument = strings.TrimSpace(argument)
+
+	switch name {
+	case "load":
+		source, err := os.ReadFile(argument)
+		if err != nil {
+			fmt.Fprintf(s.out, "could not read: %s\n", err.Error())
+			return
+		}
+		s.print(s.run(string(source), argument))
+
+	case "ast":
+		p := parser.New(lexer.New(argument))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			renderer := diagnostic.NewRenderer("<repl>", argument, Color)
+			renderer.RenderAll(s.out, diagnostic.FromParseErrors(p.ParseErrors()))
+			return
+		}
+		io.WriteString(s.out, program.String())
+		io.WriteString(s.out, "\n")
+
+	case "bytecode":
+		p := parser.New(lexer.New(argument))
+		program := p.ParseProgram()
+		renderer := diagnostic.NewRenderer("<repl>", argument, Color)
+		if len(p.Errors()) != 0 {
+			renderer.RenderAll(s.out, diagnostic.FromParseErrors(p.ParseErrors()))
+			return
+		}
+		s.machine.disassemble(program, renderer, s.out)
+
+	case "type":
+		obj := s.run(argument, "<repl>")
+		if obj != nil {
+			io.WriteString(s.out, string(obj.Type()))
+			io.WriteString(s.out, "\n")
+		}
+
+	case "env":
+		s.printEnv()
+
+	case "reset":
+		s.reset()
+		io.WriteString(s.out, "session reset\n")
+
+	case "time":
+		s.timing = !s.timing
+		if s.timing {
+			io.WriteString(s.out, "timing on\n")
+		} else {
+			io.WriteString(s.out, "timing off\n")
+		}
+
+	default:
+		fmt.Fprintf(s.out, "unknown command :%s, expected one of " +
+			":load, :ast, :bytecode, :type, :env, :reset, :time\n", name)
+	}
+}
+
+// the names defined in the session
+func (s *session) names() []string {
+	if Engine == "eval" {
+		return s.env.Names()
+	}
+
+	names := []string{}
+	for _, symbol := range s.machine.symbolTable.Globals() {
+		names = append(names, symbol.Name)
+	}
+	return names
+}
+
+// the keywords, builtins and defined names that start with the prefix
+func (s *session) complete(prefix string) []string {
+	words := token.Keywords()
+	for _, builtin := range object.Builtins {
+		words = append(words, builtin.Name)
+	}
+	words = append(words, s.names()...)
+
+	seen := make(map[string]bool)
+	matches := []string{}
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) && !seen[word] {
+			seen[word] = true
+			matches = append(matches, word)
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
+
+// lists the globals of the session with their values
+func (s *session) printEnv() {
+	if Engine == "eval" {
+		for _, name := range s.env.Names() {
+			value, _ := s.env.Get(name)
+			fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+		}
+		return
+	}
+
+	for _, symbol := range s.machine.symbolTable.Globals() {
+		var value string = "<undefined>"
+		if symbol.Index < len(s.machine.globals) && s.machine.globals[symbol.Index] != nil {
+			value = s.machine.globals[symbol.Index].Inspect()
+		}
+		fmt.Fprintf(s.out, "%s = %s\n", symbol.Name, value)
 	}
 }
 
 // takes an input and an output, reads the text from the input
 // evaluates the input in the lexer, and prints the tokens to
-// the out
+// the out. Lines are collected until they form complete input, so
+// functions and loops can be typed over several lines. Input is run
+// with the engine set by Engine. Lines starting with a colon are
+// commands, see session.command. When the input is a terminal lines are
+// read with a line editor that keeps its history in ~/.mylang_history.
+// Calling exit ends the repl
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := newSession(out)
+
+	var reader lineReader = &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		var historyFile string = ""
+		if home, err := os.UserHomeDir(); err == nil {
+			historyFile = filepath.Join(home, ".mylang_history")
+		}
+		reader = newLineEditor(file, out, historyFile, s.complete)
+	}
+
+	var input string = ""
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		var scanned bool = scanner.Scan()
-		if !scanned {
+		var prompt string = PROMPT
+		if input != "" {
+			prompt = CONTINUATION_PROMPT
+		}
+
+		text, ok := reader.readLine(prompt)
+		if !ok {
 			return
 		}
 
-		var line string = scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		var program *ast.Program = p.ParseProgram()
-		
-		if len(p.Errors()) != 0 {
-			PrintParseErrors(out, p.Errors())
+		if input == "" && strings.HasPrefix(text, ":") {
+			s.command(strings.TrimSpace(text))
+			if s.exited {
+				return
+			}
 			continue
 		}
 
-		var evaluated object.Object = evaluator.Evaluate(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		input += text
+		if lexer.Incomplete(input) {
+			input += "\n"
+			continue
+		}
+
+		var line string = input
+		input = ""
+
+		s.print(s.run(line, "<repl>"))
+		if s.exited {
+			return
 		}
 	}
 }
@@ -0,0 +1,171 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runs the lines through Start with the given engine and returns what the
+// repl printed, without the prompts
+func runRepl(t *testing.T, engine string, lines ...string) string {
+	t.Helper()
+
+	previous := Engine
+	Engine = engine
+	defer func() { Engine = previous }()
+
+	var out bytes.Buffer
+	Start(strings.NewReader(strings.Join(lines, "\n") + "\n"), &out)
+
+	output := strings.ReplaceAll(out.String(), CONTINUATION_PROMPT, "")
+	return strings.ReplaceAll(output, PROMPT, "")
+}
+
+func TestCompileErrorKeepsState(t *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		output := runRepl(t, engine,
+			"if (true) { nope }",
+			"let x = 1;",
+			"x",
+			"let f = func() { x }; f()",
+		)
+
+		if !strings.HasSuffix(output, "1\n1\n") {
+			t.Errorf("[%s] globals lost after a failed block. got=%q", engine, output)
+		}
+	}
+}
+
+func TestExitEndsRepl(t *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		output := runRepl(t, engine, "1", "if (true) { exit(2) }", "3")
+		if output != "1\n" {
+			t.Errorf("[%s] repl kept running after exit. got=%q", engine, output)
+		}
+	}
+}
+
+func TestMultilineInput(t *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		output := runRepl(t, engine,
+			"let f = func(a) {",
+			"  a * 2",
+			"};",
+			"f(21)",
+		)
+
+		if output != "42\n" {
+			t.Errorf("[%s] wrong output. want=%q, got=%q", engine, "42\n", output)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{
+			[]string{"let x = 5;", ":type x", `:type "a"`, ":type let y = 1;"},
+			"INTEGER\nSTRING\n",
+		},
+		{
+			[]string{":ast 1 + 2 * 3", ":ast let a = -b;"},
+			"(1 + (2 * 3))\nlet a = (-b);\n",
+		},
+		{
+			[]string{"let a = 1;", "let b = [a];", ":env"},
+			"a = 1\nb = [1]\n",
+		},
+		{
+			[]string{"let a = 1;", ":reset", ":env", "let b = 2;", ":env"},
+			"session reset\nb = 2\n",
+		},
+		{
+			[]string{":time", ":time"},
+			"timing on\ntiming off\n",
+		},
+		{
+			[]string{":nope"},
+			"unknown command :nope, expected one of " +
+				":load, :ast, :bytecode, :type, :env, :reset, :time\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []string{"vm", "eval"} {
+			output := runRepl(t, engine, tt.lines...)
+			if output != tt.expected {
+				t.Errorf("[%s] wrong output for %q. want=%q, got=%q",
+					engine, tt.lines, tt.expected, output)
+			}
+		}
+	}
+}
+
+// :bytecode compiles against the session without defining anything in it
+func TestBytecodeCommand(t *testing.T) {
+	output := runRepl(t, "vm", "let x = 5;", ":bytecode let y = x + 1", ":env")
+
+	expected := "0000 OpGetGlobal 0\n" +
+		"0003 OpConstant 1\n" +
+		"0006 OpAdd\n" +
+		"0007 OpSetGlobal 1\n" +
+		"x = 5\n"
+
+	if output != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, output)
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.ml")
+	err := os.WriteFile(path, []byte("let double = func(a) {\n  a * 2\n};\ndouble(2)\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write %s: %s", path, err)
+	}
+
+	for _, engine := range []string{"vm", "eval"} {
+		output := runRepl(t, engine, ":load " + path, "double(5)", ":load missing.ml")
+
+		expected := "4\n10\ncould not read: open missing.ml: no such file or directory\n"
+		if output != expected {
+			t.Errorf("[%s] wrong output. want=%q, got=%q", engine, expected, output)
+		}
+	}
+}
+
+// an error in a function defined by earlier input is shown in that input
+func TestRuntimeErrorsPointAtTheirInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.ml")
+	err := os.WriteFile(path, []byte("let half = func(a) {\n  a / \"2\"\n};\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write %s: %s", path, err)
+	}
+
+	for _, engine := range []string{"vm", "eval"} {
+		output := runRepl(t, engine,
+			"let f = func(x) {",
+			"  first(x)",
+			"};",
+			"let a = 1; f(a)",
+			":load " + path,
+			"half(1)",
+		)
+
+		for _, expected := range []string{
+			"<repl>:2:3: error: argument to `first` must be ARRAY, got INTEGER\n" +
+				"    2 |   first(x)\n" +
+				"      |   ^~~~~\n",
+			path + ":2:5: error: ",
+			"    2 |   a / \"2\"\n      |     ^\n",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("[%s] missing %q. got=%q", engine, expected, output)
+			}
+		}
+	}
+}
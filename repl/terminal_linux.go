@@ -0,0 +1,56 @@
+//go:build linux
+
+package repl
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func getTermios(fd int) (*syscall.Termios, error) {
+	termios := &syscall.Termios{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return termios, nil
+}
+
+func setTermios(fd int, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// reports whether the file descriptor is a terminal
+func isTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// puts the terminal in raw mode so keys are read one at a time without
+// being echoed, and returns a function that restores the old mode.
+// Output processing is left on so "\n" still starts a new line
+func makeRaw(fd int) (func(), error) {
+	old, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	err = setTermios(fd, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { setTermios(fd, old) }, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+// line editing is only supported on linux, other systems read plain lines
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw mode is not supported on this system")
+}
@@ -1,21 +1,31 @@
 package token
 
+import "sort"
+
 type TokenType string
 
+// Line and Column are where the token starts in the input, both
+// counting from 1
 type Token struct {
 	Type TokenType
 	Literal string
+	Line int
+	Column int
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF =     "EOF"
+	COMMENT = "COMMENT"
 	
 	// identifiers and literals
 	IDENT =  "IDENT"
 	INT =    "INT"
 	FLOAT =  "FLOAT"
 	STRING = "STRING"
+	// the part of an interpolated string before a ${, the last part
+	// is a STRING
+	TEMPLATE = "TEMPLATE"
 
 	// operators
 	ASSIGN =   "="
@@ -81,3 +91,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// returns the keywords of the language, sorted
+func Keywords() []string {
+	words := []string{}
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
@@ -10,11 +10,39 @@ import (
 )
 
 const (
-	STACKSIZE = 2048
+	STACKSIZE = 65536
 	GLOBALSIZE = 65536
 	MAXFRAMES = 1024
 )
 
+// the stack and frames start at these sizes and double as needed until
+// they reach their limits
+const (
+	initialStackSize = 256
+	initialFrames = 64
+)
+
+// limits on the resources a script can use. StackSize is the maximum
+// number of slots on the stack, MaxFrames the number of nested calls, GlobalsSize
+// the number of globals (at most GLOBALSIZE since global operands are two
+// bytes wide), and MaxAllocation the total number of bytes that can be
+// allocated for strings, arrays, hashes, and closures over the whole run.
+// A MaxAllocation of 0 means allocations are not limited, any other zero
+// or negative limit falls back to its default
+type Limits struct {
+	StackSize int
+	MaxFrames int
+	GlobalsSize int
+	MaxAllocation int
+}
+
+var DefaultLimits = Limits{
+	StackSize: STACKSIZE,
+	MaxFrames: MAXFRAMES,
+	GlobalsSize: GLOBALSIZE,
+	MaxAllocation: 0,
+}
+
 type VM struct {
 	constants []object.Object
 
@@ -25,31 +53,110 @@ type VM struct {
 
 	frames []*Frame
 	framesIndex int
+
+	// upvalues that still point at a live stack slot
+	openUpvalues []*object.Upvalue
+
+	limits Limits
+	allocated int
 }
 
 // creates the vm with the given bytecode added as the main function.
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	return NewWithLimits(bytecode, DefaultLimits)
+}
+
+// creates the vm with the given resource limits. The globals start empty
+// and grow as the script defines them, up to the limit
+func NewWithLimits(bytecode *compiler.Bytecode, limits Limits) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions: bytecode.Positions,
+		NumLocals: bytecode.NumLocals,
+	}
 	mainClosure := &object.Closure{Function: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
-	frames := make([]*Frame, MAXFRAMES)
+	if limits.StackSize <= 0 {
+		limits.StackSize = DefaultLimits.StackSize
+	}
+	if limits.MaxFrames <= 0 {
+		limits.MaxFrames = DefaultLimits.MaxFrames
+	}
+	if limits.GlobalsSize <= 0 || limits.GlobalsSize > GLOBALSIZE {
+		limits.GlobalsSize = GLOBALSIZE
+	}
+
+	frames := make([]*Frame, min(initialFrames, limits.MaxFrames))
 	frames[0] = mainFrame
 
 	return &VM{
 		constants: bytecode.Constants,
 		
-		stack: make([]object.Object, STACKSIZE),
-		sp: 0,
+		stack: make([]object.Object,
+			max(min(initialStackSize, limits.StackSize), bytecode.NumLocals)),
+		sp: bytecode.NumLocals,
 
-		globals: make([]object.Object, GLOBALSIZE),
+		globals: []object.Object{},
 
 		frames: frames,
 		framesIndex: 1,
+
+		limits: limits,
 	}
 }
 
+// creates the vm with the globals left by an earlier run, so that the
+// repl can run each input with the globals defined by the ones before it.
+// The globals grow as new ones are defined, the current list is returned
+// by Globals
+func NewWithGlobals(
+	bytecode *compiler.Bytecode,
+	globals []object.Object,
+	limits Limits,
+) *VM {
+	vm := NewWithLimits(bytecode, limits)
+	vm.globals = globals
+	return vm
+}
+
+// returns the globals defined so far
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// an error the vm stopped with, along with where in the source the
+// instruction that failed was compiled from. The Line of the position is
+// 0 when it is not known
+type RuntimeError struct {
+	Message string
+	Position code.Position
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// runs the main function to its end. Errors are returned as a
+// RuntimeError at the instruction that failed, except for the Exit of the
+// exit builtin which is returned as it is
 func (vm *VM) Run() error {
+	err := vm.execute()
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*object.Exit); ok {
+		return err
+	}
+
+	frame := vm.currentFrame()
+	return &RuntimeError{
+		Message: err.Error(),
+		Position: code.PositionAt(frame.closure.Function.Positions, frame.ip),
+	}
+}
+
+func (vm *VM) execute() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -122,11 +229,35 @@ func (vm *VM) Run() error {
 			array := vm.buildArray(vm.sp - numElements, vm.sp)
 			vm.sp = vm.sp - numElements
 
-			err := vm.push(array)
+			err := vm.allocate(array)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(array)
 			if err != nil {
 				return err
 			}
 		
+		// joins the parts of an interpolated string on top of the stack.
+		// The first operand gives the number of parts
+		case code.OpInterpolate:
+			numParts := int(binary.BigEndian.Uint16(ins[ip + 1:]))
+			vm.currentFrame().ip += 2
+
+			str := object.Interpolate(vm.stack[vm.sp - numParts:vm.sp])
+			vm.sp = vm.sp - numParts
+
+			err := vm.allocate(str)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(str)
+			if err != nil {
+				return err
+			}
+
 		// builds the hash from the elements on top of the stack.
 		// the first operand gives the number of keys/values in the hash
 		case code.OpHash:
@@ -138,6 +269,11 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+			err = vm.allocate(hash)
+			if err != nil {
+				return err
+			}
+
 			vm.sp = vm.sp - numElements
 			err = vm.push(hash)
 			if err != nil {
@@ -155,6 +291,29 @@ func (vm *VM) Run() error {
 				return err
 			}
 		
+		// takes the step, end and start of the slice and the value being
+		// sliced from the top of the stack
+		case code.OpSlice:
+			step := vm.pop()
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			slice, err := object.Slice(left, start, end, step)
+			if err != nil {
+				return err
+			}
+
+			err = vm.allocate(slice)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(slice)
+			if err != nil {
+				return err
+			}
+
 		// gets the number of arguments passed to the function from
 		// the top of the stack from the first operand of OpCall
 		case code.OpCall:
@@ -166,12 +325,24 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		// like OpCall but for calls in tail position. Closures are called
+		// in the current frame instead of a new one
+		case code.OpTailCall:
+			numArgs := int(uint8(ins[ip + 1]))
+			vm.currentFrame().ip += 1
+
+			err := vm.executeTailCall(numArgs)
+			if err != nil {
+				return err
+			}
+
 		// ( returns from the current function by taking the frame off the
 		// frame stack and setting the stack pointer to the value
 		// it was before the function call. It then puts null on top of
 		// the stack because there were no return values
 		case code.OpReturn:
 			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(object.NULL)
@@ -187,6 +358,7 @@ func (vm *VM) Run() error {
 			returnValue := vm.pop()  // get return value from top of the stack
 
 			frame := vm.popFrame()  // return to the outer frame
+			vm.closeUpvalues(frame.basePointer)
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(returnValue)  // replace closure with return value
@@ -217,18 +389,21 @@ func (vm *VM) Run() error {
 		// operand of the instruction and assigns the object on top of the
 		// stack to that index in the globals pool
 		case code.OpSetGlobal:
-			globalIndex := binary.BigEndian.Uint16(ins[ip + 1:])
+			globalIndex := int(binary.BigEndian.Uint16(ins[ip + 1:]))
 			vm.currentFrame().ip += 2
 
-			vm.globals[globalIndex] = vm.pop()
+			err := vm.setGlobal(globalIndex, vm.pop())
+			if err != nil {
+				return err
+			}
 
 		// puts the object assosiated with the index provided by the operand
 		// on top of the stack
 		case code.OpGetGlobal:
-			globalIndex := binary.BigEndian.Uint16(ins[ip + 1:])
+			globalIndex := int(binary.BigEndian.Uint16(ins[ip + 1:]))
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.push(vm.getGlobal(globalIndex))
 			if err != nil {
 				return err
 			}
@@ -261,13 +436,47 @@ func (vm *VM) Run() error {
 			freeIndex := uint8(ins[ip + 1])
 			vm.currentFrame().ip += 1
 
-			vm.currentFrame().closure.Free[freeIndex] = vm.pop()
+			upvalue := vm.currentFrame().closure.Free[freeIndex]
+			vm.setUpvalue(upvalue, vm.pop())
 
 		// gets the free variable from the current frame
 		case code.OpGetFree:
 			freeIndex := uint8(ins[ip + 1])
 			vm.currentFrame().ip += 1
 
+			currentClosure := vm.currentFrame().closure
+			err := vm.push(vm.getUpvalue(currentClosure.Free[freeIndex]))
+			if err != nil {
+				return err
+			}
+
+		// closes the upvalues of the locals from the slot given by the
+		// operand up, used when a block scope ends
+		case code.OpClose:
+			localIndex := int(uint8(ins[ip + 1]))
+			vm.currentFrame().ip += 1
+
+			vm.closeUpvalues(vm.currentFrame().basePointer + localIndex)
+
+		// puts an upvalue for the local given by the operand on the stack,
+		// to be taken by the OpClosure that follows. Closures capturing the
+		// same local get the same upvalue
+		case code.OpCaptureLocal:
+			localIndex := int(uint8(ins[ip + 1]))
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+
+			err := vm.push(vm.captureUpvalue(frame.basePointer + localIndex))
+			if err != nil {
+				return err
+			}
+
+		// puts the upvalue of the current closure given by the operand on
+		// the stack, so the new closure shares it
+		case code.OpCaptureFree:
+			freeIndex := uint8(ins[ip + 1])
+			vm.currentFrame().ip += 1
+
 			currentClosure := vm.currentFrame().closure
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
@@ -329,11 +538,18 @@ func (vm *VM) StackTop() object.Object {
 
 // returns the value that was most recently taken off the stack
 func (vm *VM) LastPoppedStackElement() object.Object {
+	if vm.sp >= len(vm.stack) {
+		return nil
+	}
 	return vm.stack[vm.sp]
 }
 
+// the name of the function the vm is running, <main> at the top level
 func (vm *VM) LastFrame() string {
-	return vm.currentFrame().ClosureName()
+	if vm.framesIndex == 1 {
+		return "<main>"
+	}
+	return functionName(vm.currentFrame())
 }
 
 func (vm *VM) DumpStack() {
@@ -345,8 +561,9 @@ func (vm *VM) DumpStack() {
 // puts the object on top of the stack and increments the stack pointer
 // first checks if the stack has space
 func (vm *VM) push(obj object.Object) error {
-	if vm.sp >= STACKSIZE {
-		return fmt.Errorf("stack overflow")
+	if vm.sp >= len(vm.stack) && !vm.growStack(vm.sp + 1) {
+		return fmt.Errorf("stack overflow: more than %d stack slots used",
+			vm.limits.StackSize)
 	}
 
 	vm.stack[vm.sp] = obj
@@ -368,10 +585,109 @@ func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex - 1]
 }
 
-// adds the given frame to the frame stack
-func (vm *VM) pushFrame(f *Frame) {
+// grows the stack so it has at least the given number of slots. Returns
+// false if that would go over the stack limit
+func (vm *VM) growStack(size int) bool {
+	if size <= len(vm.stack) {
+		return true
+	}
+	if size > vm.limits.StackSize {
+		return false
+	}
+
+	newSize := min(max(2 * len(vm.stack), size), vm.limits.StackSize)
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+
+	return true
+}
+
+// adds the given frame to the frame stack, growing the frame stack if it
+// is full. Returns an error with the call chain if the frame limit has
+// been reached
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= len(vm.frames) {
+		if len(vm.frames) >= vm.limits.MaxFrames {
+			return vm.recursionError(f)
+		}
+
+		newSize := min(2 * len(vm.frames), vm.limits.MaxFrames)
+		frames := make([]*Frame, newSize)
+		copy(frames, vm.frames)
+		vm.frames = frames
+	}
+
 	vm.frames[vm.framesIndex] = f
 	vm.framesIndex++
+
+	return nil
+}
+
+// error for a call that could not be made because the frames or the
+// stack ran out. Lists the functions on the frame stack starting with
+// the function that was being called
+func (vm *VM) recursionError(callee *Frame) error {
+	names := []string{functionName(callee)}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		names = append(names, functionName(vm.frames[i]))
+	}
+	names = append(names, "<main>")
+
+	return fmt.Errorf("maximum recursion depth exceeded: %s",
+		object.CallChain(names))
+}
+
+func functionName(f *Frame) string {
+	if f.ClosureName() == "" {
+		return "<anonymous>"
+	}
+	return f.ClosureName()
+}
+
+// stores the value in the globals, growing them if the index has not
+// been used yet
+func (vm *VM) setGlobal(index int, obj object.Object) error {
+	if index >= vm.limits.GlobalsSize {
+		return fmt.Errorf("globals overflow: more than %d globals defined",
+			vm.limits.GlobalsSize)
+	}
+
+	for index >= len(vm.globals) {
+		vm.globals = append(vm.globals, nil)
+	}
+
+	vm.globals[index] = obj
+	return nil
+}
+
+// returns the global at the index, or null if it has not been set
+func (vm *VM) getGlobal(index int) object.Object {
+	if index >= len(vm.globals) || vm.globals[index] == nil {
+		return object.NULL
+	}
+
+	return vm.globals[index]
+}
+
+// adds the size of the newly created object to the amount allocated by
+// the vm and returns an error if it goes over the allocation limit
+func (vm *VM) allocate(obj object.Object) error {
+	return vm.allocateBytes(object.SizeOf(obj))
+}
+
+// adds the bytes to the amount allocated by the vm and returns an error
+// if it goes over the allocation limit
+func (vm *VM) allocateBytes(size int) error {
+	vm.allocated += size
+
+	if vm.limits.MaxAllocation > 0 && vm.allocated > vm.limits.MaxAllocation {
+		return fmt.Errorf("allocation limit exceeded: more than %d bytes allocated",
+			vm.limits.MaxAllocation)
+	}
+
+	return nil
 }
 
 // removes the current frame from the frame stack
@@ -390,17 +706,75 @@ func (vm *VM) pushClosure(constIndex, numFree int) error {
 	}
 
 	// order of free variables matter because of how they are referenced with
-	// opGetFree, which has the index of the variable in the list
-	free := make([]object.Object, numFree)
+	// opGetFree, which has the index of the variable in the list. Values
+	// that were not captured by reference get their own closed upvalue
+	free := make([]*object.Upvalue, numFree)
 	for i := 0; i < numFree; i++ {
-		free[i] = vm.stack[vm.sp - numFree + i]
+		obj := vm.stack[vm.sp - numFree + i]
+		if upvalue, ok := obj.(*object.Upvalue); ok {
+			free[i] = upvalue
+		} else {
+			free[i] = &object.Upvalue{Value: obj}
+		}
 	}
 	vm.sp = vm.sp - numFree
 
 	closure := &object.Closure{Function: function, Free: free}
+
+	err := vm.allocate(closure)
+	if err != nil {
+		return err
+	}
+
 	return vm.push(closure)
 }
 
+// returns the open upvalue for the stack slot, creating it if no closure
+// has captured the slot yet
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	for _, upvalue := range vm.openUpvalues {
+		if upvalue.Slot == slot {
+			return upvalue
+		}
+	}
+
+	upvalue := &object.Upvalue{Slot: slot, Open: true}
+	vm.openUpvalues = append(vm.openUpvalues, upvalue)
+	return upvalue
+}
+
+// closes every open upvalue at or above the given stack slot by copying
+// the value off the stack. Called when the slots are about to be reused
+func (vm *VM) closeUpvalues(from int) {
+	open := vm.openUpvalues[:0]
+
+	for _, upvalue := range vm.openUpvalues {
+		if upvalue.Slot >= from {
+			upvalue.Value = vm.stack[upvalue.Slot]
+			upvalue.Open = false
+		} else {
+			open = append(open, upvalue)
+		}
+	}
+
+	vm.openUpvalues = open
+}
+
+func (vm *VM) getUpvalue(upvalue *object.Upvalue) object.Object {
+	if upvalue.Open {
+		return vm.stack[upvalue.Slot]
+	}
+	return upvalue.Value
+}
+
+func (vm *VM) setUpvalue(upvalue *object.Upvalue, value object.Object) {
+	if upvalue.Open {
+		vm.stack[upvalue.Slot] = value
+	} else {
+		upvalue.Value = value
+	}
+}
+
 // gets the function from the stack, which is located under the number
 // of arguments passed to the function
 func (vm *VM) executeCall(numArgs int) error {
@@ -415,6 +789,38 @@ func (vm *VM) executeCall(numArgs int) error {
 	}
 }
 
+// calls the function under the arguments on the stack in place of the
+// current function. For closures the callee and arguments are moved down
+// to where the current function and its arguments were, and the current
+// frame starts over with the new closure, so tail recursion runs without
+// using more frames or stack. Builtins are called as normal, their result
+// is returned by the OpReturnValue that follows
+func (vm *VM) executeTailCall(numArgs int) error {
+	callee, ok := vm.stack[vm.sp - 1 - numArgs].(*object.Closure)
+	if !ok {
+		return vm.executeCall(numArgs)
+	}
+
+	if numArgs != callee.Function.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
+			callee.Function.NumParameters, numArgs)
+	}
+
+	frame := vm.currentFrame()
+	if !vm.growStack(frame.basePointer + callee.Function.NumLocals + 1) {
+		return vm.recursionError(NewFrame(callee, frame.basePointer))
+	}
+
+	vm.closeUpvalues(frame.basePointer)
+	copy(vm.stack[frame.basePointer - 1:], vm.stack[vm.sp - 1 - numArgs : vm.sp])
+
+	frame.closure = callee
+	frame.ip = -1
+	vm.sp = frame.basePointer + callee.Function.NumLocals
+
+	return nil
+}
+
 // first checks that the number of arguments given matches the number 
 // of parameters to the function. Then creates a new frame for the 
 // function with the base pointer being the stack pointer minus the number
@@ -429,7 +835,14 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	}
 
 	frame := NewFrame(cl, vm.sp - numArgs)
-	vm.pushFrame(frame)
+	if !vm.growStack(frame.basePointer + cl.Function.NumLocals + 1) {
+		return vm.recursionError(frame)
+	}
+
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
 
 	vm.sp = frame.basePointer + cl.Function.NumLocals
 
@@ -437,15 +850,49 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 }
 
 // gets the slice of arguments from the stack and calls the builtin with
-// the arguments. Pushes the result on the stack
+// the arguments. Pushes the result on the stack, or returns it as the
+// error if the builtin failed
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp - numArgs : vm.sp]
 
+	// append grows the builder it is given in place, so what the builder
+	// grows by is counted, as it is not a new object
+	var builder *object.Builder
+	var size int
+	if numArgs > 0 {
+		if b, ok := args[0].(*object.Builder); ok {
+			builder, size = b, object.SizeOf(b)
+		}
+	}
+
 	result := builtin.Function(args...)
 	vm.sp = vm.sp - numArgs - 1
-	vm.push(result)
 
-	return nil
+	// exit stops the vm, Run returns the exit as its error
+	if exit, ok := result.(*object.Exit); ok {
+		return exit
+	}
+
+	// an error from a builtin stops the vm like any other runtime error,
+	// as it stops the evaluator
+	if errObj, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", errObj.Message)
+	}
+
+	if !isArgument(result, args) {
+		err := vm.allocate(result)
+		if err != nil {
+			return err
+		}
+	}
+	if builder != nil {
+		err := vm.allocateBytes(object.SizeOf(builder) - size)
+		if err != nil {
+			return err
+		}
+	}
+
+	return vm.push(result)
 }
 
 // executes the binary operation based on the types of the values on top of the
@@ -466,9 +913,9 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	case op == code.OpEqual:
-		return vm.push(getBoolObject(left == right))
+		return vm.push(getBoolObject(object.Equal(left, right)))
 	case op == code.OpNotEqual:
-		return vm.push(getBoolObject(left != right))
+		return vm.push(getBoolObject(!object.Equal(left, right)))
 	}
 
 	return fmt.Errorf("unsupported types for binary operation: %s %s",
@@ -542,7 +989,14 @@ func (vm *VM) executeBinaryStringOperation(
 
 	switch op {
 	case code.OpAdd:
-		return vm.push(&object.String{Value: leftValue + rightValue})
+		result := &object.String{Value: leftValue + rightValue}
+
+		err := vm.allocate(result)
+		if err != nil {
+			return err
+		}
+
+		return vm.push(result)
 	case code.OpEqual:
 		return vm.push(getBoolObject(leftValue == rightValue))
 	case code.OpNotEqual:
@@ -580,13 +1034,14 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 func (vm *VM) executeStringIndex(str, index object.Object) error {
 	strObj := str.(*object.String)
 	idx := index.(*object.Integer).Value
-	maximum := int64(len(strObj.Value) - 1)
+	runes := strObj.Runes()
+	maximum := int64(len(runes) - 1)
 
 	if idx < 0 || idx > maximum {
 		return vm.push(object.NULL)
 	}
 
-	return vm.push(&object.String{Value: string(strObj.Value[idx])})
+	return vm.push(&object.String{Value: string(runes[idx])})
 }
 
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
@@ -597,7 +1052,7 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return vm.push(object.NULL)
 	}
@@ -616,23 +1071,21 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
 	for i := 0; i < endIndex - startIndex; i += 2 {
 		key := vm.stack[startIndex + i]
 		value := vm.stack[startIndex + i + 1]
 
-		pair := object.HashPair{Key: key, Value: value}
-
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
 			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
 		}
 
-		hashedPairs[hashKey.HashKey()] = pair
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: hashedPairs}, nil
+	return hash, nil
 }
 
 func (vm *VM) executeNotOperator() error {
@@ -671,6 +1124,17 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// checks if the object returned by a builtin is one of the arguments
+// passed to it, so it is not counted as a new allocation
+func isArgument(obj object.Object, args []object.Object) bool {
+	for _, arg := range args {
+		if obj == arg {
+			return true
+		}
+	}
+	return false
+}
+
 func getBoolObject(value bool) *object.Boolean {
 	if value {
 		return object.TRUE
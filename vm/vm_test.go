@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"mylang/ast"
 	"mylang/compiler"
+	"mylang/evaluator"
 	"mylang/lexer"
 	"mylang/object"
 	"mylang/parser"
@@ -66,6 +67,17 @@ func TestBooleanExpressions(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1] == [\"1\"]", false},
+		{"{\"a\": [1], \"b\": 2} == {\"b\": 2, \"a\": [1]}", true},
+		{"{\"a\": 1} == {\"a\": 2}", false},
+		{"{\"a\": 1} == {\"b\": 1}", false},
+		{"[] == {}", false},
+		{"1 == \"1\"", false},
 	}
 	runVmTests(t, tests)
 }
@@ -75,6 +87,18 @@ func TestStringExpressions(t *testing.T) {
 		{`"monkey"`, "monkey"},
 		{`"mon" + "key"`, "monkey"},
 		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`len("héllo")`, 5},
+		{`"héllo"[1]`, "é"},
+		{`"日本語"[2]`, "語"},
+		{`"日本語"[3]`, object.NULL},
+		{`assign("日本語", 1, "x")`, "日x語"},
+		{`pop("日本語")`, "日本"},
+		{`bytes("aé")`, []int{97, 195, 169}},
+		{`runes("aé")`, []int{97, 233}},
+		{`let x = 2; "x=${x}, ${x * 2}${"!"}"`, "x=2, 4!"},
+		{`"${[1, "a"]} ${{"k": true}} ${if (false) { 1 }}"`, "[1, a] {k: true} null"},
+		{`let f = func(n) { "<${n}>" }; "${f(1)}${f("${2}")}"`, "<1><2>"},
+		{`"\${x}"`, "${x}"},
 	}
 
 	runVmTests(t, tests)
@@ -158,6 +182,44 @@ func TestIndexExpressions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`[1, 2, 3, 4, 5][1:3]`, []int{2, 3}},
+		{`[1, 2, 3, 4, 5][:2]`, []int{1, 2}},
+		{`[1, 2, 3, 4, 5][3:]`, []int{4, 5}},
+		{`[1, 2, 3, 4, 5][:]`, []int{1, 2, 3, 4, 5}},
+		{`[1, 2, 3, 4, 5][-2:]`, []int{4, 5}},
+		{`[1, 2, 3, 4, 5][:-3]`, []int{1, 2}},
+		{`[1, 2, 3, 4, 5][::2]`, []int{1, 3, 5}},
+		{`[1, 2, 3, 4, 5][::-1]`, []int{5, 4, 3, 2, 1}},
+		{`[1, 2, 3, 4, 5][3:0:-1]`, []int{4, 3, 2}},
+		{`[1, 2, 3, 4, 5][-10:10]`, []int{1, 2, 3, 4, 5}},
+		{`[1, 2, 3, 4, 5][4:1]`, []int{}},
+		{`let a = [1, 2, 3]; let i = 1; a[i:i + 1]`, []int{2}},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[::-1]`, "olléh"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"abc"[5:]`, ""},
+		{`[1, 2, 3][2::9223372036854775807]`, []int{3}},
+		{`[1, 2, 3][::-9223372036854775807]`, []int{3}},
+		{`"abc"[-9223372036854775807:9223372036854775807]`, "abc"},
+		{
+			`[1, 2, 3][::0]`,
+			&object.Error{Message: "slice step cannot be zero"},
+		},
+		{
+			`[1, 2, 3]["a":]`,
+			&object.Error{Message: "slice start must be INTEGER, got STRING"},
+		},
+		{
+			`{1: 2}[0:1]`,
+			&object.Error{Message: "slice operator not supported: HASH"},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestCallingFunctionsWithoutArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -463,6 +525,123 @@ func TestBuiltinFunctions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestImmutableStrings(t *testing.T) {
+	tests := []vmTestCase{
+		// the literal is one constant, changing it would change it for
+		// the next call too
+		{
+			input: `
+		let f = func() {
+			let s = "abc";
+			let t = assign(s, 0, "x");
+			s + t
+		};
+		f();
+		f();
+		`,
+			expected: "abcxbc",
+		},
+		{`let s = "ab"; let t = pop(s); s + t`, "aba"},
+		{`pop("")`, object.NULL},
+		{`string(append(append(builder(), "ab"), 1, true))`, "ab1true"},
+		{`len(append(builder(), "abc", "de"))`, 5},
+		{`len(append(builder(), "é世"))`, 2},
+		{`type(builder())`, "BUILDER"},
+		{`append("a", "b")`,
+			&object.Error{
+				Message: "argument 1 to `append` must be BUILDER, got STRING",
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`split("a,b,,c", ",")`, []string{"a", "b", "", "c"}},
+		{`split("héj", "")`, []string{"h", "é", "j"}},
+		{
+			`split(1, ",")`,
+			&object.Error{Message: "argument 1 to `split` must be STRING, got INTEGER"},
+		},
+		{`join(["a", 1, true], "-")`, "a-1-true"},
+		{`join([], ",")`, ""},
+		{
+			`let a = []; let i = 0; while (i < 300) { a = push(a, i); i = i + 1; }; join(a, repeat("-", 1000000))`,
+			&object.Error{Message: "result of `join` is too large, more than 268435456 bytes"},
+		},
+		{
+			`join("ab", ",")`,
+			&object.Error{Message: "argument 1 to `join` must be ARRAY, got STRING"},
+		},
+		{`trim("  a b \n")`, "a b"},
+		{`trimLeft("  a ") + "."`, "a ."},
+		{`trimRight("  a ") + "."`, "  a."},
+		{
+			`trim()`,
+			&object.Error{Message: "wrong number of arguments to `trim`. got=0, want=1"},
+		},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("abc", "", "-")`, "-a-b-c-"},
+		{
+			`let s = repeat("a", 4000); replace(s, "", repeat("b", 100000))`,
+			&object.Error{Message: "result of `replace` is too large, more than 268435456 bytes"},
+		},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "xyz")`, false},
+		{`startsWith("hello", "he")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ABC")`, "abc"},
+		{`repeat("ab", 3)`, "ababab"},
+		{
+			`repeat("ab", -1)`,
+			&object.Error{Message: "argument 2 to `repeat` must not be negative, got -1"},
+		},
+		{
+			`repeat("ab", 9223372036854775807)`,
+			&object.Error{Message: "result of `repeat` is too large, more than 268435456 bytes"},
+		},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`substr("héllo", 1)`, "éllo"},
+		{`substr("héllo", 1, 2)`, "él"},
+		{`substr("héllo", 3, 10)`, "lo"},
+		{
+			`substr("héllo", 6)`,
+			&object.Error{Message: "start of `substr` out of range, got 6 for length 5"},
+		},
+		{
+			`substr("a")`,
+			&object.Error{Message: "wrong number of arguments to `substr`. got=1, want 2 or 3"},
+		},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`lines("a\r\nb\n\nc\n")`, []string{"a", "b", "", "c"}},
+		{`lines("")`, []string{}},
+		{`padLeft("7", 3, "0")`, "007"},
+		{`padRight("ab", 5, "-=")`, "ab-=-"},
+		{`padRight("a", 7, "é-")`, "aé-é-é-"},
+		{`padLeft("abc", 2)`, "abc"},
+		{`padLeft("a", 3) + "."`, "  a."},
+		{
+			`padLeft("a", 3, "")`,
+			&object.Error{Message: "padding of `padLeft` must not be empty"},
+		},
+		{
+			`padLeft("a", 1000000000000)`,
+			&object.Error{Message: "result of `padLeft` is too large, more than 268435456 bytes"},
+		},
+		{
+			`padLeft("a", 9223372036854775807, "xy")`,
+			&object.Error{Message: "result of `padLeft` is too large, more than 268435456 bytes"},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -542,6 +721,230 @@ func TestClosures(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestMutableClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+		let newCounter = func() {
+			let count = 0;
+			func() { count = count + 1; count };
+		};
+		let counter = newCounter();
+		counter();
+		counter();
+		counter();
+		`,
+			expected: 3,
+		},
+		{
+			input: `
+		let newCounter = func() {
+			let count = 0;
+			let inc = func() { count = count + 1; };
+			let get = func() { count };
+			[inc, get];
+		};
+		let counter = newCounter();
+		counter[0]();
+		counter[0]();
+		counter[1]();
+		`,
+			expected: 2,
+		},
+		{
+			input: `
+		let f = func() {
+			let x = 1;
+			let set = func(v) { x = v; };
+			set(10);
+			x;
+		};
+		f();
+		`,
+			expected: 10,
+		},
+		{
+			input: `
+		let f = func() {
+			let x = 1;
+			let outer = func() {
+				func() { x = x + 1; };
+			};
+			outer()();
+			outer()();
+			x;
+		};
+		f();
+		`,
+			expected: 3,
+		},
+		{
+			input: `
+		let a = func() { let n = 0; func() { n = n + 1; n } };
+		let one = a();
+		let two = a();
+		one();
+		one();
+		two();
+		`,
+			expected: 1,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+		let x = 1;
+		if (true) { let x = x + 10; x };
+		`,
+			expected: 11,
+		},
+		{
+			input: `
+		let x = 1;
+		if (true) { let x = x + 10; };
+		x;
+		`,
+			expected: 1,
+		},
+		{
+			input: `
+		let i = 0;
+		let sum = 0;
+		while (i < 3) {
+			let sq = i * i;
+			sum = sum + sq;
+			i = i + 1;
+		};
+		sum;
+		`,
+			expected: 5,
+		},
+		{
+			input: `
+		let f = func(x) {
+			switch (x) {
+				case 1 { let y = 10; y }
+				default { let z = 20; x + z }
+			}
+		};
+		f(1) + f(2);
+		`,
+			expected: 32,
+		},
+		{
+			input: `
+		let fs = [];
+		let i = 0;
+		while (i < 3) {
+			let j = i;
+			fs = push(fs, func() { j });
+			i = i + 1;
+		};
+		fs[0]() + fs[1]() * 10 + fs[2]() * 100;
+		`,
+			expected: 210,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+// runs each program on both engines and checks they give the same result
+func TestClosureConformance(t *testing.T) {
+	tests := []string{
+		`
+		let newCounter = func() {
+			let count = 0;
+			func() { count = count + 1; count };
+		};
+		let counter = newCounter();
+		counter();
+		counter() + counter();
+		`,
+		`
+		let make = func() {
+			let fs = [];
+			let i = 0;
+			while (i < 3) {
+				let j = i;
+				fs = push(fs, func() { j });
+				i = i + 1;
+			}
+			fs;
+		};
+		let fs = make();
+		[fs[0](), fs[1](), fs[2]()];
+		`,
+		`
+		let make = func() {
+			let fs = [];
+			let i = 0;
+			while (i < 3) {
+				fs = push(fs, func() { i = i + 10; i });
+				i = i + 1;
+			}
+			let get = func() { i };
+			[fs, get];
+		};
+		let pair = make();
+		pair[0][0]();
+		pair[0][2]();
+		pair[1]();
+		`,
+		`
+		let account = func(balance) {
+			let deposit = func(n) { balance = balance + n; balance };
+			let withdraw = func(n) { balance = balance - n; balance };
+			{"deposit": deposit, "withdraw": withdraw};
+		};
+		let acc = account(100);
+		acc["deposit"](50);
+		acc["withdraw"](30);
+		`,
+		`
+		let x = 1;
+		let f = func() {
+			let x = 2;
+			if (x > 1) {
+				let x = x * 10;
+				while (x < 100) { let y = x; x = y + 50; };
+				x;
+			}
+		};
+		[x, f()];
+		`,
+	}
+
+	for _, input := range tests {
+		program := parse(input)
+
+		expected := evaluator.Evaluate(program, object.NewEnvironment())
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.MakeBytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		actual := vm.LastPoppedStackElement()
+		if actual.Inspect() != expected.Inspect() {
+			t.Errorf("engines disagree on %s\nevaluator=%s, vm=%s",
+				input, expected.Inspect(), actual.Inspect())
+		}
+	}
+}
+
 func TestRecursiveFunctions(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -589,11 +992,302 @@ func TestRecursiveFunctions(t *testing.T) {
 		`,
 			expected: 0,
 		},
+		{
+			input: `
+		let sum = func(x) {
+			if (x == 0) {
+				return 0;
+			} else {
+				x + sum(x - 1);
+			}
+		};
+		sum(1000);
+		`,
+			expected: 500500,
+		},
 	}
 
 	runVmTests(t, tests)
 }
 
+// exit stops the vm wherever it is called, Run returns it as the error
+func TestExit(t *testing.T) {
+	for _, input := range []string{
+		"exit(3); 5",
+		"let f = func() { while (true) { exit(3); } }; f(); 5",
+		"let f = func(x) { if (x == 0) { exit(3) }; f(x - 1) }; [f(10), 5]",
+		"switch (1) { case 1 { let a = exit(3); } }; 5",
+	} {
+		comp := compiler.New()
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.MakeBytecode())
+		err = vm.Run()
+		exit, ok := err.(*object.Exit)
+		if !ok {
+			t.Errorf("vm did not exit for %q. got=%v", input, err)
+			continue
+		}
+		if exit.Status != 3 {
+			t.Errorf("wrong exit status for %q. want=3, got=%d", input, exit.Status)
+		}
+	}
+}
+
+func TestBuiltinErrorsStopTheVm(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{`first("x"); 5`, "argument to `first` must be ARRAY, got STRING"},
+		{`let f = func() { len(1) }; [f(), 5]`, "argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.MakeBytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Errorf("vm did not stop for %q. last=%v", tt.input, vm.LastPoppedStackElement())
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong vm error for %q. expected=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestSandboxDenialsStopTheVm(t *testing.T) {
+	object.SetSandbox(&object.Sandbox{Paths: []string{}})
+	defer object.SetSandbox(nil)
+
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{`args(); 5`, "sandbox denied `args`: capability \"env\" is not granted"},
+		{`exit(3); 5`, "sandbox denied `exit`: capability \"exit\" is not granted"},
+		{`command("ls"); 5`, "sandbox denied `command`: capability \"exec\" is not granted"},
+		{
+			`let f = func() { open("data.txt") }; [f(), 5]`,
+			"sandbox denied `open`: capability \"fs\" is not granted",
+		},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.MakeBytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Errorf("vm did not stop for %q. last=%v", tt.input, vm.LastPoppedStackElement())
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong vm error for %q. expected=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestLastFrame(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{`first(1)`, "<main>"},
+		{`let f = func() { first(1) }; f()`, "f"},
+		{`(func() { first(1) })()`, "<anonymous>"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.MakeBytecode())
+		if err := vm.Run(); err == nil {
+			t.Fatalf("expected a vm error for %q", tt.input)
+		}
+		if vm.LastFrame() != tt.expected {
+			t.Errorf("wrong last frame for %q. want=%q, got=%q", tt.input, tt.expected, vm.LastFrame())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+		let count = func(x, acc) {
+			if (x == 0) {
+				return acc;
+			}
+			count(x - 1, acc + 1);
+		};
+		count(100000, 0);
+		`,
+			expected: 100000,
+		},
+		{
+			input: `
+		let isOdd = func(x, even) {
+			if (x == 0) { false } else { even(x - 1, isOdd) }
+		};
+		let isEven = func(x, odd) {
+			if (x == 0) { true } else { odd(x - 1, isEven) }
+		};
+		isEven(5001, isOdd);
+		`,
+			expected: false,
+		},
+		{
+			input: `
+		let f = func(x) {
+			switch (x) {
+				case 0 { len("done") }
+				default { return f(x - 1); }
+			}
+		};
+		f(5000);
+		`,
+			expected: 4,
+		},
+		{
+			input: `
+		let wrapper = func(a) {
+			let inner = func(b, c) { a + b + c };
+			inner(2, 3);
+		};
+		wrapper(1) + wrapper(10);
+		`,
+			expected: 21,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected string
+	}{
+		{
+			`let f = func(x) { 1 + f(x + 1) }; f(0);`,
+			Limits{MaxFrames: 16},
+			"maximum recursion depth exceeded: f (x16) <- <main>",
+		},
+		{
+			`let f = func(a, b, c) { let d = 1; 1 + f(a, b, c) }; f(1, 2, 3);`,
+			Limits{StackSize: 64, MaxFrames: 1024},
+			"maximum recursion depth exceeded: f (x11) <- <main>",
+		},
+		{
+			`let a = []; while (true) { a = push(a, 1); }`,
+			Limits{MaxAllocation: 4096},
+			"allocation limit exceeded: more than 4096 bytes allocated",
+		},
+		{
+			`let b = builder(); while (true) { append(b, "0123456789"); }`,
+			Limits{MaxAllocation: 4096},
+			"allocation limit exceeded: more than 4096 bytes allocated",
+		},
+		{
+			`let g = func(x) { x + 1 }; let f = func(x) { g(f(x)) }; f(0);`,
+			Limits{MaxFrames: 8},
+			"maximum recursion depth exceeded: f (x8) <- <main>",
+		},
+		{
+			`[1, 2, 3, 4, 5, 6, 7, 8, 9]`,
+			Limits{StackSize: 8},
+			"stack overflow: more than 8 stack slots used",
+		},
+		{
+			`let a = 1; let b = 2; let c = 3;`,
+			Limits{GlobalsSize: 2},
+			"globals overflow: more than 2 globals defined",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := NewWithLimits(comp.MakeBytecode(), tt.limits)
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestPersistentGlobals(t *testing.T) {
+	inputs := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 5; let add = func(a) { a + x };", nil},
+		{"if (true) { let y = 10; add(y) }", 15},
+		{"x = x + 1; let counter = func() { x = x + 1; x };", nil},
+		{"counter(); counter()", 8},
+		{"let z = len([1, 2]); add(z) + x", 18},
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	constants := []object.Object{}
+	globals := []object.Object{}
+
+	for _, tt := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+
+		bytecode := comp.MakeBytecode()
+		constants = bytecode.Constants
+
+		vm := NewWithGlobals(bytecode, globals, DefaultLimits)
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+		globals = vm.Globals()
+
+		if tt.expected == nil {
+			continue
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElement())
+	}
+}
+
 func runVmTests(t *testing.T, tests []vmTestCase) {
 	t.Helper()
 
@@ -608,6 +1302,14 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 
 		vm := New(comp.MakeBytecode())
 		err = vm.Run()
+
+		// errors the vm stops with are expected like error objects
+		if expected, ok := test.expected.(*object.Error); ok && err != nil {
+			if err.Error() != expected.Message {
+				t.Errorf("wrong vm error. expected=%q, got=%q", expected.Message, err)
+			}
+			continue
+		}
 		if err != nil {
 			t.Fatalf("vm error: %s", err)
 		}
@@ -666,6 +1368,25 @@ func testExpectedObject(
 				t.Errorf("testIntegerObject failed: %s", err)
 			}
 		}
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testStringObject(expectedElem, array.Elements[i])
+			if err != nil {
+				t.Errorf("testStringObject failed: %s", err)
+			}
+		}
 	case map[object.HashKey]int64:
 		hash, ok := actual.(*object.Hash)
 		if !ok {
@@ -673,16 +1394,16 @@ func testExpectedObject(
 			return
 		}
 
-		if len(hash.Pairs) != len(expected) {
+		if hash.Len() != len(expected) {
 			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d",
-				len(expected), len(hash.Pairs))
+				len(expected), hash.Len())
 			return
 		}
 
-		for expectedKey, expectedValue := range expected {
-			pair, ok := hash.Pairs[expectedKey]
+		for _, pair := range hash.Pairs() {
+			expectedValue, ok := expected[pair.Key.(object.Hashable).HashKey()]
 			if !ok {
-				t.Errorf("no pair for given key in Pairs")
+				t.Errorf("unexpected key in Pairs: %s", pair.Key.Inspect())
 			}
 
 			err := testIntegerObject(expectedValue, pair.Value)